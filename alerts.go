@@ -0,0 +1,466 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zieckey/goini"
+)
+
+// Types of alert rules.
+const (
+	RuleUnreachable    = "unreachable"
+	RuleOccurrenceDrop = "occurrence_drop"
+	RuleVisibility     = "visibility"
+	RuleIPTVersion     = "ipt_version"
+)
+
+// Alert severities, least severe first.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert statuses.
+const (
+	AlertNew      = "new"
+	AlertOngoing  = "ongoing"
+	AlertResolved = "resolved"
+)
+
+// AlertRule is a rule read from an alert config. Runs is how many consecutive
+// runs an IPT must be unreachable, or a vanished resource alerted on,
+// Percent the occurrence drop between runs, per resource or per IPT by
+// Scope, and MinVersion the oldest IPT version accepted.
+type AlertRule struct {
+	Name       string
+	Type       string
+	Severity   string
+	Runs       int
+	Percent    float64
+	Scope      string
+	MinVersion string
+}
+
+// ReadAlertRules reads an ini file where each section is a rule, e.g.:
+//
+//	[ipt down]
+//	type=unreachable
+//	runs=3
+//	severity=critical
+//
+//	[occurrences dropped]
+//	type=occurrence_drop
+//	percent=10
+//	scope=resource
+//
+//	[became private]
+//	type=visibility
+//	runs=3
+//	severity=info
+//
+//	[old ipt]
+//	type=ipt_version
+//	min=2.3
+//
+// Severity defaults to warning, runs to 1, percent to 10 and scope to
+// resource. Visibility rules alert on resources whose visibility changed and
+// on resources no longer listed by a reachable IPT: its home page, crawled
+// anonymously, doesn't list private resources, so resources made private, or
+// deleted, vanish from it. As deleting is often deliberate, alerts of
+// vanished resources resolve after runs runs even if they don't reappear.
+func ReadAlertRules(path string) ([]AlertRule, error) {
+	ini := goini.New()
+	if err := ini.ParseFile(path); err != nil {
+		return nil, err
+	}
+
+	rules := []AlertRule{}
+	for name, kv := range ini.GetAll() {
+		if name == "" {
+			continue
+		}
+		rule := AlertRule{Name: name, Type: kv["type"], Severity: kv["severity"], Runs: 1, Percent: 10,
+			Scope: kv["scope"], MinVersion: kv["min"]}
+		if rule.Severity == "" {
+			rule.Severity = SeverityWarning
+		}
+		if rule.Scope == "" {
+			rule.Scope = "resource"
+		}
+		if runs, ok := kv["runs"]; ok {
+			n, err := strconv.Atoi(runs)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("Rule %s: invalid runs %q", name, runs)
+			}
+			rule.Runs = n
+		}
+		if percent, ok := kv["percent"]; ok {
+			p, err := strconv.ParseFloat(strings.TrimSuffix(percent, "%"), 64)
+			if err != nil || !(p > 0 && p <= 100) {
+				return nil, fmt.Errorf("Rule %s: invalid percent %q", name, percent)
+			}
+			rule.Percent = p
+		}
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	return rules, nil
+}
+
+func (r AlertRule) validate() error {
+	switch r.Type {
+	case RuleUnreachable, RuleOccurrenceDrop, RuleVisibility:
+	case RuleIPTVersion:
+		if r.MinVersion == "" {
+			return fmt.Errorf("Rule %s: missing min version", r.Name)
+		}
+	default:
+		return fmt.Errorf("Rule %s: unknown type %q", r.Name, r.Type)
+	}
+	switch r.Severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("Rule %s: unknown severity %q", r.Name, r.Severity)
+	}
+	if r.Scope != "resource" && r.Scope != "ipt" {
+		return fmt.Errorf("Rule %s: unknown scope %q", r.Name, r.Scope)
+	}
+	return nil
+}
+
+// compareVersions compares IPT versions like 2.3.4-r68469e8 by their numeric
+// parts, returning -1, 0 or 1.
+func compareVersions(a, b string) int {
+	parts := func(v string) []int {
+		if i := strings.IndexAny(v, "-_ "); i >= 0 {
+			v = v[:i]
+		}
+		n := []int{}
+		for _, p := range strings.Split(v, ".") {
+			i, _ := strconv.Atoi(p)
+			n = append(n, i)
+		}
+		return n
+	}
+	pa, pb := parts(a), parts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// AlertEvent is a status change of an alert at Time.
+type AlertEvent struct {
+	Time   time.Time
+	Status string
+}
+
+// Alert is raised by Rule for an IPT, or a Resource of it, published by
+// Organization, when set. Resource is the key of the resource, or its link
+// when it has none. Status, FirstSeen, LastSeen, Resolved, Runs, the
+// number of runs it fired, and History are kept by AlertState across runs.
+type Alert struct {
+	Rule         string
+	Severity     string
+	IPT          string
+	Resource     ResourceKey
+	Organization string
+	Message      string
+	Status       string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	Resolved     time.Time
+	Runs         int
+	History      []AlertEvent
+}
+
+// ID identifies the alert across runs.
+func (a Alert) ID() string {
+	return a.Rule + "|" + a.IPT + "|" + string(a.Resource)
+}
+
+// EvaluateRules returns the alerts rules raise on history, snapshots of
+// consecutive runs oldest first, the last being the latest crawl. Open are
+// the alerts open before, see AlertState: resources which vanished keep
+// firing, also while their IPT fails, until they reappear or have fired for
+// the runs of their rule.
+func EvaluateRules(rules []AlertRule, history []*Snapshot, open []Alert) []Alert {
+	alerts := []Alert{}
+	if len(history) == 0 {
+		return alerts
+	}
+	latest := history[len(history)-1]
+	var previous *Snapshot
+	if len(history) > 1 {
+		previous = history[len(history)-2]
+	}
+
+	for _, rule := range rules {
+		raise := func(ipt string, r *Resource, format string, args ...interface{}) {
+			a := Alert{Rule: rule.Name, Severity: rule.Severity, IPT: ipt, Message: fmt.Sprintf(format, args...)}
+			if r != nil {
+				a.Resource, a.Organization = ResourceKey(resourceID(*r)), r.Organization
+			}
+			alerts = append(alerts, a)
+		}
+
+		for _, ipt := range latest.IPTs {
+			switch rule.Type {
+			case RuleUnreachable:
+				if len(history) < rule.Runs {
+					continue
+				}
+				down := true
+				for _, s := range history[len(history)-rule.Runs:] {
+					if i := s.IPT(ipt.Name); i == nil || i.Healthy() {
+						down = false
+						break
+					}
+				}
+				if down {
+					raise(ipt.Name, nil, "unreachable for %d consecutive runs: %s", rule.Runs, ipt.Err)
+				}
+
+			case RuleOccurrenceDrop:
+				if previous == nil || !ipt.Healthy() {
+					continue
+				}
+				old := previous.IPT(ipt.Name)
+				if old == nil || !old.Healthy() {
+					continue
+				}
+				dropped := func(before, after int) bool {
+					return before > 0 && float64(before-after)/float64(before)*100 > rule.Percent
+				}
+				if rule.Scope == "ipt" {
+					before, after := 0, 0
+					for _, r := range old.Resources {
+						before += r.Occurrences
+					}
+					for _, r := range ipt.Resources {
+						after += r.Occurrences
+					}
+					if dropped(before, after) {
+						raise(ipt.Name, nil, "occurrences dropped from %d to %d", before, after)
+					}
+					continue
+				}
+				current := map[string]Resource{}
+				for _, r := range ipt.Resources {
+					current[resourceID(r)] = r
+				}
+				for _, r := range old.Resources {
+					if cur, ok := current[resourceID(r)]; ok && dropped(r.Occurrences, cur.Occurrences) {
+						raise(ipt.Name, &cur, "%s: occurrences dropped from %d to %d", cur.Name, r.Occurrences, cur.Occurrences)
+					}
+				}
+
+			case RuleVisibility:
+				// open alerts of resources still missing keep firing, also
+				// while the IPT fails, for the runs of the rule
+				listed := map[string]Resource{}
+				for _, r := range ipt.Resources {
+					listed[resourceID(r)] = r
+				}
+				firing := map[string]bool{}
+				for _, a := range open {
+					if a.Rule != rule.Name || a.IPT != ipt.Name || a.Resource == "" || a.Runs >= rule.Runs {
+						continue
+					}
+					if _, ok := listed[string(a.Resource)]; !ok {
+						firing[string(a.Resource)] = true
+						alerts = append(alerts, Alert{Rule: rule.Name, Severity: rule.Severity, IPT: ipt.Name,
+							Resource: a.Resource, Organization: a.Organization, Message: a.Message})
+					}
+				}
+				if !ipt.Healthy() {
+					continue
+				}
+
+				// compared with the last run the IPT was reachable
+				var last *IPTSnapshot
+				for _, s := range history[:len(history)-1] {
+					if i := s.IPT(ipt.Name); i != nil && i.Healthy() {
+						last = i
+					}
+				}
+				if last == nil {
+					continue
+				}
+				for _, r := range last.Resources {
+					id := resourceID(r)
+					cur, ok := listed[id]
+					switch {
+					case firing[id]:
+					case !ok:
+						raise(ipt.Name, &r, "%s: no longer listed, made private or deleted", r.Name)
+					case r.Visibility != "" && cur.Visibility != "" && r.Visibility != cur.Visibility:
+						raise(ipt.Name, &cur, "%s: visibility changed from %s to %s", cur.Name, r.Visibility, cur.Visibility)
+					}
+				}
+
+			case RuleIPTVersion:
+				if ipt.Version != "" && compareVersions(ipt.Version, rule.MinVersion) < 0 {
+					raise(ipt.Name, nil, "IPT version %s below %s", ipt.Version, rule.MinVersion)
+				}
+			}
+		}
+	}
+
+	return alerts
+}
+
+// AlertState keeps every alert raised, open or resolved, across runs, and
+// the new and resolved alerts owners couldn't be notified of yet by owner
+// name, see NotifyConfig.Dispatch.
+type AlertState struct {
+	Updated     time.Time
+	Alerts      []Alert
+	Undelivered map[string][]Alert
+}
+
+// ReadAlertState reads the state saved at path. A missing file is an empty
+// state.
+func ReadAlertState(path string) (*AlertState, error) {
+	state := &AlertState{}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Write saves the state at path.
+func (s *AlertState) Write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Update merges the alerts firing at now. Alerts seen before are deduplicated
+// as ongoing, open alerts not firing anymore are resolved and resolved ones
+// firing again are new again. It returns the alerts which changed status to
+// new or resolved, e.g. to notify.
+func (s *AlertState) Update(firing []Alert, now time.Time) []Alert {
+	index := map[string]int{}
+	for i, a := range s.Alerts {
+		index[a.ID()] = i
+	}
+
+	changed := []Alert{}
+	fired := map[string]bool{}
+	for _, f := range firing {
+		if fired[f.ID()] {
+			continue
+		}
+		fired[f.ID()] = true
+
+		i, ok := index[f.ID()]
+		if !ok {
+			s.Alerts = append(s.Alerts, f)
+			i = len(s.Alerts) - 1
+		}
+		a := &s.Alerts[i]
+		a.Severity, a.Message, a.LastSeen = f.Severity, f.Message, now
+		a.Runs++
+		if !ok || a.Status == AlertResolved {
+			a.Status, a.FirstSeen, a.Resolved = AlertNew, now, time.Time{}
+			a.History = append(a.History, AlertEvent{Time: now, Status: AlertNew})
+			changed = append(changed, *a)
+		} else {
+			a.Status = AlertOngoing
+		}
+	}
+
+	for i := range s.Alerts {
+		a := &s.Alerts[i]
+		if !fired[a.ID()] && a.Status != AlertResolved {
+			a.Status, a.Resolved = AlertResolved, now
+			a.History = append(a.History, AlertEvent{Time: now, Status: AlertResolved})
+			changed = append(changed, *a)
+		}
+	}
+
+	sort.SliceStable(s.Alerts, func(i, j int) bool { return s.Alerts[i].ID() < s.Alerts[j].ID() })
+	s.Updated = now
+	return changed
+}
+
+// Open returns the alerts not resolved, most severe first.
+func (s *AlertState) Open() []Alert {
+	open := []Alert{}
+	for _, a := range s.Alerts {
+		if a.Status != AlertResolved {
+			open = append(open, a)
+		}
+	}
+	SortAlerts(open)
+	return open
+}
+
+// severityRank orders severities, most severe first.
+var severityRank = map[string]int{SeverityCritical: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// SortAlerts sorts alerts most severe first, then by ID.
+func SortAlerts(alerts []Alert) {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		return a.ID() < b.ID()
+	})
+}
+
+// Describe returns a line of text describing the alert.
+func (a Alert) Describe() string {
+	subject := a.IPT
+	if a.Resource != "" {
+		subject += " " + string(a.Resource)
+	}
+	return fmt.Sprintf("%s %s [%s] %s: %s", strings.ToUpper(a.Status), a.Severity, a.Rule, subject, a.Message)
+}
+
+// WriteAlerts writes a line per alert.
+func WriteAlerts(w io.Writer, alerts []Alert) error {
+	buf := bufio.NewWriter(w)
+	for _, a := range alerts {
+		fmt.Fprintln(buf, a.Describe())
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,215 @@
+package iptReport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadAlertRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tableCases := []struct {
+		config      string
+		output      []AlertRule
+		shouldError bool
+	}{
+		{"[down]\ntype=unreachable\nruns=3\nseverity=critical\n[drop]\ntype=occurrence_drop\npercent=20%\nscope=ipt\n",
+			[]AlertRule{
+				{Name: "down", Type: RuleUnreachable, Severity: SeverityCritical, Runs: 3, Percent: 10, Scope: "resource"},
+				{Name: "drop", Type: RuleOccurrenceDrop, Severity: SeverityWarning, Runs: 1, Percent: 20, Scope: "ipt"},
+			}, false},
+		{"[old]\ntype=ipt_version\n", nil, true},
+		{"[x]\ntype=unknown\n", nil, true},
+		{"[x]\ntype=unreachable\nseverity=urgent\n", nil, true},
+		{"[x]\ntype=unreachable\nruns=0\n", nil, true},
+		{"[x]\ntype=occurrence_drop\npercent=0\n", nil, true},
+		{"[x]\ntype=occurrence_drop\npercent=-5\n", nil, true},
+		{"[x]\ntype=occurrence_drop\npercent=150%\n", nil, true},
+	}
+
+	for i, tt := range tableCases {
+		path := filepath.Join(dir, "alerts.ini")
+		if err := ioutil.WriteFile(path, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadAlertRules(path)
+		if (err != nil) != tt.shouldError {
+			t.Errorf("case %d: got error %v", i, err)
+			continue
+		}
+		if !tt.shouldError && !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("case %d: got \n%#v, want \n%#v", i, got, tt.output)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tableCases := []struct {
+		a, b   string
+		output int
+	}{
+		{"2.3.4-r68469e8", "2.3", 1},
+		{"2.2.1", "2.3", -1},
+		{"2.3", "2.3.0", 0},
+		{"2.10", "2.9", 1},
+	}
+
+	for _, tt := range tableCases {
+		if got := compareVersions(tt.a, tt.b); got != tt.output {
+			t.Errorf("%s vs %s: got %d, want %d", tt.a, tt.b, got, tt.output)
+		}
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	r := func(shortname, visibility string, occurrences int) Resource {
+		return Resource{Name: shortname, Link: "http://ipt.example.org/resource?r=" + shortname,
+			Visibility: visibility, Occurrences: occurrences}
+	}
+	snapshot := func(ipts ...IPTSnapshot) *Snapshot { return &Snapshot{IPTs: ipts} }
+	history := []*Snapshot{
+		snapshot(IPTSnapshot{Name: "a", Version: "2.2", Resources: []Resource{r("birds", "Public", 100), r("fish", "Public", 50)}},
+			IPTSnapshot{Name: "b", Err: "timeout"}),
+		snapshot(IPTSnapshot{Name: "a", Version: "2.2", Resources: []Resource{r("birds", "Private", 80), r("fish", "Public", 49)}},
+			IPTSnapshot{Name: "b", Err: "timeout"}),
+	}
+	birds := NewResourceKey("http://ipt.example.org", "birds")
+
+	tableCases := []struct {
+		rule   AlertRule
+		output []Alert
+	}{
+		{AlertRule{Name: "down", Type: RuleUnreachable, Severity: SeverityCritical, Runs: 2},
+			[]Alert{{Rule: "down", Severity: SeverityCritical, IPT: "b", Message: "unreachable for 2 consecutive runs: timeout"}}},
+		{AlertRule{Name: "down", Type: RuleUnreachable, Runs: 3}, []Alert{}},
+		{AlertRule{Name: "drop", Type: RuleOccurrenceDrop, Percent: 10, Scope: "resource"},
+			[]Alert{{Rule: "drop", IPT: "a", Resource: birds, Message: "birds: occurrences dropped from 100 to 80"}}},
+		{AlertRule{Name: "drop", Type: RuleOccurrenceDrop, Percent: 20, Scope: "ipt"}, []Alert{}},
+		{AlertRule{Name: "old", Type: RuleIPTVersion, MinVersion: "2.3"},
+			[]Alert{{Rule: "old", IPT: "a", Message: "IPT version 2.2 below 2.3"}}},
+	}
+
+	for _, tt := range tableCases {
+		if got := EvaluateRules([]AlertRule{tt.rule}, history, nil); !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("%s: got \n%#v, want \n%#v", tt.rule.Name, got, tt.output)
+		}
+	}
+}
+
+func TestEvaluateVisibility(t *testing.T) {
+	r := func(shortname string) Resource {
+		return Resource{Name: shortname, Link: "http://ipt.example.org/resource?r=" + shortname, Organization: "INPA", Visibility: "Public"}
+	}
+	snapshot := func(ipts ...IPTSnapshot) *Snapshot { return &Snapshot{IPTs: ipts} }
+	both := snapshot(IPTSnapshot{Name: "a", Resources: []Resource{r("birds"), r("fish")}})
+	fish := snapshot(IPTSnapshot{Name: "a", Resources: []Resource{r("fish")}})
+	down := snapshot(IPTSnapshot{Name: "a", Err: "timeout"})
+	hidden := r("fish")
+	hidden.Visibility = "Private"
+	private := snapshot(IPTSnapshot{Name: "a", Resources: []Resource{r("birds"), hidden}})
+	unkeyed := snapshot(IPTSnapshot{Name: "a", Resources: []Resource{
+		{Name: "one", Link: "http://ipt.example.org/one"}, {Name: "two", Link: "http://ipt.example.org/two"}}})
+	empty := snapshot(IPTSnapshot{Name: "a", Resources: []Resource{}})
+	rule := AlertRule{Name: "private", Type: RuleVisibility, Severity: SeverityInfo, Runs: 2}
+	vanished := Alert{Rule: "private", Severity: SeverityInfo, IPT: "a", Resource: NewResourceKey("http://ipt.example.org", "birds"),
+		Organization: "INPA", Message: "birds: no longer listed, made private or deleted"}
+	fired := vanished
+	fired.Runs = 1
+	expired := vanished
+	expired.Runs = 2
+	changed := Alert{Rule: "private", Severity: SeverityInfo, IPT: "a", Resource: NewResourceKey("http://ipt.example.org", "fish"),
+		Organization: "INPA", Message: "fish: visibility changed from Public to Private"}
+	gone := func(link, name string) Alert {
+		return Alert{Rule: "private", Severity: SeverityInfo, IPT: "a", Resource: ResourceKey(link),
+			Message: name + ": no longer listed, made private or deleted"}
+	}
+
+	tableCases := []struct {
+		name    string
+		history []*Snapshot
+		open    []Alert
+		output  []Alert
+	}{
+		{"vanished", []*Snapshot{both, fish}, nil, []Alert{vanished}},
+		{"vanished while failing", []*Snapshot{both, down, fish}, nil, []Alert{vanished}},
+		{"failing", []*Snapshot{both, down}, nil, []Alert{}},
+		{"still private while failing", []*Snapshot{fish, down}, []Alert{fired}, []Alert{vanished}},
+		{"still private", []*Snapshot{both, fish, fish}, []Alert{fired}, []Alert{vanished}},
+		{"fired its runs", []*Snapshot{both, fish, fish}, []Alert{expired}, []Alert{}},
+		{"reappeared", []*Snapshot{fish, both}, []Alert{fired}, []Alert{}},
+		{"made private", []*Snapshot{both, private}, nil, []Alert{changed}},
+		{"without keys", []*Snapshot{unkeyed, empty}, nil,
+			[]Alert{gone("http://ipt.example.org/one", "one"), gone("http://ipt.example.org/two", "two")}},
+	}
+
+	for _, tt := range tableCases {
+		if got := EvaluateRules([]AlertRule{rule}, tt.history, tt.open); !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("%s: got \n%#v, want \n%#v", tt.name, got, tt.output)
+		}
+	}
+}
+
+func TestAlertStateUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	day := func(d int) time.Time { return time.Date(2018, 6, d, 0, 0, 0, 0, time.UTC) }
+	down := Alert{Rule: "down", Severity: SeverityCritical, IPT: "b", Message: "unreachable"}
+	old := Alert{Rule: "old", Severity: SeverityInfo, IPT: "a", Message: "old version"}
+
+	runs := []struct {
+		firing  []Alert
+		changed []string
+		open    int
+	}{
+		{[]Alert{down, old}, []string{AlertNew, AlertNew}, 2},
+		{[]Alert{down, old, down}, []string{}, 2},
+		{[]Alert{old}, []string{AlertResolved}, 1},
+		{[]Alert{old, down}, []string{AlertNew}, 2},
+	}
+
+	for i, run := range runs {
+		state, err := ReadAlertState(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed := state.Update(run.firing, day(i+1))
+		statuses := []string{}
+		for _, a := range changed {
+			statuses = append(statuses, a.Status)
+		}
+		if !reflect.DeepEqual(statuses, run.changed) {
+			t.Errorf("run %d: got changes %v, want %v", i, statuses, run.changed)
+		}
+		if open := len(state.Open()); open != run.open {
+			t.Errorf("run %d: got %d open alerts, want %d", i, open, run.open)
+		}
+		if err := state.Write(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state, err := ReadAlertState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := state.Open()[0]
+	if a.Rule != "down" || a.Runs != 3 || !a.FirstSeen.Equal(day(4)) || len(a.History) != 3 {
+		t.Errorf("got %#v", a)
+	}
+	if a = state.Open()[1]; a.Status != AlertOngoing || a.Runs != 4 || !a.FirstSeen.Equal(day(1)) {
+		t.Errorf("got %#v", a)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	rulesFile := flag.String("rules", "alerts.ini", "ini file of alert rules")
+	dir := flag.String("dir", "snapshots", "directory of the snapshots saved by report2csv -snapshots")
+	stateFile := flag.String("state", "alerts.json", "file keeping the alerts across runs")
+	since := flag.Duration("since", 30*24*time.Hour, "how far back the snapshots evaluated go")
+	all := flag.Bool("all", false, "write every open alert, not only new and resolved ones")
+	notifyFile := flag.String("notify", "", "ini file of the owners to notify of new and resolved alerts, empty to skip")
+
+	flag.Parse()
+
+	rules, err := report.ReadAlertRules(*rulesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st, err := report.OpenSnapshotStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	latest, err := st.Latest()
+	if err != nil {
+		log.Fatal(err)
+	}
+	history, err := st.Between(latest.Taken.Add(-*since), latest.Taken)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var notify *report.NotifyConfig
+	if *notifyFile != "" {
+		if notify, err = report.ReadNotifyConfig(*notifyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	state, err := report.ReadAlertState(*stateFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	changed := []report.Alert{}
+	evaluated := state.Updated.Before(latest.Taken)
+	if evaluated {
+		changed = state.Update(report.EvaluateRules(rules, history, state.Open()), latest.Taken)
+		report.SortAlerts(changed)
+	} else {
+		log.Printf("snapshot %s already evaluated", latest.ID())
+	}
+	if notify != nil {
+		var errs []error
+		state.Undelivered, errs = notify.Dispatch(changed, state.Undelivered)
+		for _, err := range errs {
+			log.Println(err)
+		}
+	}
+	if err := state.Write(*stateFile); err != nil {
+		log.Fatal(err)
+	}
+	if evaluated && !*all {
+		if err := report.WriteAlerts(os.Stdout, changed); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *all {
+		if err := report.WriteAlerts(os.Stdout, state.Open()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sort"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini")
+	uri := flag.String("uri", "", "IRI identifying the merged catalogue (required)")
+	title := flag.String("title", "IPT inventory", "title of the merged catalogue")
+	format := flag.String("format", "turtle", "output format, turtle or jsonld")
+	crawlAll := flag.Bool("crawl", false, "build every dataset from a crawl instead of the IPT /dcat endpoints")
+
+	flag.Parse()
+
+	if *uri == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format != "turtle" && *format != "jsonld" {
+		log.Fatalf("unknown format %s", *format)
+	}
+
+	ipts, err := report.ReadIPTs(*iniFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	aliases := make([]string, 0, len(ipts))
+	for alias := range ipts {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	// IPTs without a readable /dcat are crawled instead
+	catalogs := []*report.DCATCatalog{}
+	uncataloged := map[string]string{}
+	for _, alias := range aliases {
+		if *crawlAll {
+			uncataloged[alias] = ipts[alias]
+			continue
+		}
+		catalog, err := report.FetchDCAT(report.DCATURL(ipts[alias]))
+		if err != nil {
+			log.Printf("%s: %v", alias, err)
+			uncataloged[alias] = ipts[alias]
+			continue
+		}
+		catalogs = append(catalogs, catalog)
+	}
+	if len(uncataloged) > 0 {
+		crawled := report.Crawl(uncataloged)
+		for _, ipt := range crawled {
+			if ipt.Err != nil {
+				log.Printf("%s: %v", ipt.Name, ipt.Err)
+			}
+		}
+		catalogs = append(catalogs, report.CatalogFromIPTs("", "", crawled))
+	}
+
+	merged := report.MergeCatalogs(*uri, *title, catalogs...)
+	if *format == "jsonld" {
+		err = merged.WriteJSONLD(os.Stdout)
+	} else {
+		err = merged.WriteTurtle(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+}
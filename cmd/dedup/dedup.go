@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	dir := flag.String("dir", "archives", "directory with the archives downloaded by verifyarchives")
+	iniFile := flag.String("file", "", "path to ipts.ini, to tell archives of the same resource under different aliases")
+
+	flag.Parse()
+
+	ipts := map[string]string{}
+	if *iniFile != "" {
+		var err error
+		if ipts, err = report.ReadIPTs(*iniFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	d := report.NewDeduplicator()
+	err := report.WalkArchives(*dir, func(ipt, shortname, path string) error {
+		a, err := report.OpenArchive(path)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			return nil
+		}
+		defer a.Close()
+		ref := report.ResourceRef{IPT: ipt, Resource: shortname, Key: report.NewResourceKey(ipts[ipt], shortname)}
+		if err := d.AddArchive(ref, a); err != nil {
+			log.Printf("%s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := d.WriteCSV(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+}
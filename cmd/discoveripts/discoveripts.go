@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini, created or updated")
+	api := flag.String("api", report.GBIFAPI, "address of the GBIF API")
+	country := flag.String("country", "", "ISO 3166 code of the country of the hosting organizations, e.g. BR")
+	node := flag.String("node", "", "key of the endorsing node")
+	prune := flag.Bool("prune", false, "remove IPTs added from the registry which it no longer lists")
+	dryRun := flag.Bool("n", false, "only report the changes, leaving the ini file untouched")
+
+	flag.Parse()
+
+	if *country == "" && *node == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	previous, sources := map[string]string{}, map[string]string{}
+	contents, err := ioutil.ReadFile(*iniFile)
+	if err == nil {
+		if previous, err = report.ReadIPTs(*iniFile); err != nil {
+			log.Fatal(err)
+		}
+		if sources, err = report.ReadIPTSources(*iniFile); err != nil {
+			log.Fatal(err)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+
+	filter := report.InstallationFilter{Country: *country, Node: *node, Type: report.InstallationIPT}
+	installations, err := report.NewGBIFRegistry(*api).Installations(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d := report.Discover(installations, previous, sources, *prune)
+	for _, alias := range d.Added {
+		log.Printf("added %s %s", alias, d.IPTs[alias])
+	}
+	for _, alias := range d.Removed {
+		log.Printf("removed %s %s", alias, previous[alias])
+	}
+	if *dryRun {
+		return
+	}
+
+	if err := writeIPTs(*iniFile, contents, d); err != nil {
+		log.Fatal(err)
+	}
+
+}
+
+// writeIPTs updates the ini file at path, whose previous contents are given,
+// replacing it only once fully written so it is never left truncated.
+func writeIPTs(path string, contents []byte, d report.Discovery) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".ipts")
+	if err != nil {
+		return err
+	}
+	if err := report.UpdateIPTs(tmp, bytes.NewReader(contents), d); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	dir := flag.String("dir", "snapshots", "directory of the snapshots saved by report2csv -snapshots")
+	level := flag.String("level", report.LevelNational, "aggregation level: resource, ipt, organization or national")
+	interval := flag.String("interval", report.IntervalMonthly, "period of each observation: weekly, monthly or yearly")
+	metric := flag.String("metric", report.MetricOccurrences, "metric to forecast: resources, occurrences, events or measurements")
+	model := flag.String("model", report.TrendLinear, "trend model: linear or exponential")
+	at := flag.String("at", "", "day to forecast, e.g. 2027-12-31, empty for a year from now")
+	target := flag.Float64("target", 0, "value to estimate when it is reached, 0 to skip")
+	z := flag.Float64("z", report.Z95, "width of the confidence bands in standard errors")
+	series := flag.String("series", "", "only forecast the series with this name, e.g. an IPT alias")
+	aliasFile := flag.String("aliases", "", "ini file mapping canonical organization names to their aliases")
+
+	flag.Parse()
+
+	when := time.Now().AddDate(1, 0, 0)
+	var err error
+	if *at != "" {
+		if when, err = time.Parse("2006-01-02", *at); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	orgs := report.NewOrgNormalizer()
+	if *aliasFile != "" {
+		if orgs, err = report.ReadOrgAliases(*aliasFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	st, err := report.OpenSnapshotStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	snapshots, err := st.Between(time.Time{}, time.Time{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ts, err := report.BuildTimeSeries(snapshots, *level, *interval, orgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *series != "" {
+		ts.Points = ts.Series(*series)
+	}
+	forecasts, err := report.ForecastSeries(ts, *metric, *model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := report.WriteForecastCSV(os.Stdout, forecasts, when, *z, *target); err != nil {
+		log.Fatal(err)
+	}
+
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini")
+	api := flag.String("api", report.GBIFAPI, "address of the GBIF API")
+	all := flag.Bool("all", false, "also list resources without issues")
+
+	flag.Parse()
+
+	ipts, err := report.ReadIPTs(*iniFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	IPTs := report.Crawl(ipts)
+	for _, ipt := range IPTs {
+		if ipt.Err != nil {
+			log.Printf("%s: %v", ipt.Name, ipt.Err)
+		}
+		for _, err := range ipt.BindErrs {
+			log.Println(err)
+		}
+	}
+
+	check := report.CheckRegistry(report.NewGBIFRegistry(*api), IPTs)
+	for _, err := range check.Errs {
+		log.Println(err)
+	}
+	if err := check.WriteCSV(os.Stdout, *all); err != nil {
+		log.Fatal(err)
+	}
+
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	goalsFile := flag.String("goals", "goals.ini", "ini file of the goals")
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini, empty to take the latest snapshot as current")
+	dir := flag.String("dir", "snapshots", "directory of the snapshots saved by report2csv -snapshots")
+	aliasFile := flag.String("aliases", "", "ini file mapping canonical organization names to their aliases")
+	format := flag.String("format", "text", "output format, text or csv")
+	risk := flag.Bool("risk", false, "only write goals at risk or missed")
+
+	flag.Parse()
+
+	if *format != "text" && *format != "csv" {
+		log.Fatalf("unknown format %s", *format)
+	}
+
+	goals, err := report.ReadGoals(*goalsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	orgs := report.NewOrgNormalizer()
+	if *aliasFile != "" {
+		if orgs, err = report.ReadOrgAliases(*aliasFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	st, err := report.OpenSnapshotStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	history, err := st.Between(time.Time{}, time.Time{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now()
+	if *iniFile != "" {
+		ipts, err := report.ReadIPTs(*iniFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		IPTs := report.Crawl(ipts)
+		for _, ipt := range IPTs {
+			if ipt.Err != nil {
+				log.Printf("%s: %v", ipt.Name, ipt.Err)
+			}
+		}
+		history = append(history, report.NewSnapshot(IPTs, now, time.Since(now)))
+	} else if len(history) == 0 {
+		log.Fatalf("no snapshot at %s", *dir)
+	}
+
+	goalsReport := report.EvaluateGoals(goals, history, now, orgs)
+	if *risk {
+		goalsReport.Goals = goalsReport.AtRisk()
+	}
+	if *format == "csv" {
+		err = goalsReport.WriteCSV(os.Stdout)
+	} else {
+		err = goalsReport.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+}
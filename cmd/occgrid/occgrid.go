@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	dir := flag.String("dir", "archives", "directory with the archives downloaded by verifyarchives")
+	size := flag.Float64("size", 1, "cell size in degrees")
+	geojson := flag.String("geojson", "grid.geojson", "where to write the GeoJSON grid, empty to skip")
+	csvFile := flag.String("csv", "grid.csv", "where to write the cell counts per IPT, empty to skip")
+
+	flag.Parse()
+
+	grid, err := report.NewGrid(*size)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = report.WalkArchives(*dir, func(ipt, shortname, path string) error {
+		a, err := report.OpenArchive(path)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			return nil
+		}
+		defer a.Close()
+		if err := grid.AddArchive(ipt, a); err != nil {
+			log.Printf("%s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *geojson != "" {
+		if err := write(*geojson, grid.WriteGeoJSON); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *csvFile != "" {
+		if err := write(*csvFile, grid.WriteCSV); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+}
+
+func write(path string, fn func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini")
+	by := flag.String("sort", "occurrences", "leaderboard order: "+strings.Join(report.RollupOrders, ", "))
+	aliasFile := flag.String("aliases", "", "ini file mapping canonical organization names to their aliases")
+	candidatesFile := flag.String("candidates", "", "where to write proposed organization merges for review, empty to skip")
+
+	flag.Parse()
+
+	ipts, err := report.ReadIPTs(*iniFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	normalizer := report.NewOrgNormalizer()
+	if *aliasFile != "" {
+		if normalizer, err = report.ReadOrgAliases(*aliasFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	IPTs := report.Crawl(ipts)
+	for _, ipt := range IPTs {
+		if ipt.Err != nil {
+			log.Printf("%s: %v", ipt.Name, ipt.Err)
+		}
+		for _, err := range ipt.BindErrs {
+			log.Println(err)
+		}
+	}
+
+	rollups := report.Rollup(IPTs, normalizer)
+	if *candidatesFile != "" {
+		names := []string{}
+		for _, o := range rollups {
+			names = append(names, o.Spellings...)
+		}
+		file, err := os.Create(*candidatesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		if err := report.WriteMergeCandidates(file, normalizer.Candidates(names)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := report.SortRollups(rollups, *by); err != nil {
+		log.Fatal(err)
+	}
+	if err := report.WriteRollupCSV(os.Stdout, rollups); err != nil {
+		log.Fatal(err)
+	}
+
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	dir := flag.String("dir", "archives", "directory with the archives downloaded by verifyarchives")
+	regionsFile := flag.String("regions", "regions.geojson", "GeoJSON FeatureCollection with the region polygons")
+	property := flag.String("property", "name", "feature property naming each region")
+
+	flag.Parse()
+
+	regions, err := report.ReadRegions(*regionsFile, *property)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tally := report.NewRegionTally(regions)
+	err = report.WalkArchives(*dir, func(ipt, shortname, path string) error {
+		a, err := report.OpenArchive(path)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			return nil
+		}
+		defer a.Close()
+		if err := tally.AddArchive(ipt, a); err != nil {
+			log.Printf("%s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := tally.WriteCSV(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+}
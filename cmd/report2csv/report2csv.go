@@ -1,124 +1,79 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"log"
 	"os"
-	"strconv"
 	"time"
 
 	report "github.com/dvdscripter/iptReport"
-	"github.com/zieckey/goini"
 )
 
 func main() {
 
-	IPTs := []report.IPT{}
 	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini")
+	stateFile := flag.String("state", "", "state file enabling incremental crawls driven by the IPT RSS feeds")
+	fullEvery := flag.Duration("full", 7*24*time.Hour, "interval between full crawls of incremental runs")
+	snapshotDir := flag.String("snapshots", "", "directory where to save the crawl as a snapshot, empty to skip")
+	siblings := flag.String("siblings", "", "suggest or include IPT instances found on the hosts of the crawled ones")
+	format := flag.String("format", "csv", "output format: csv, tsv, json, ndjson, markdown or xlsx")
 
 	flag.Parse()
 
-	ini := goini.New()
-	err := ini.ParseFile(*iniFile)
+	if *siblings != "" && *siblings != "suggest" && *siblings != "include" {
+		log.Fatalf("unknown siblings mode %s", *siblings)
+	}
+	start := time.Now()
+	rw, err := report.NewReportWriter(os.Stdout, *format, start)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	result := make(chan report.IPTResult)
-	count := 0
-
-	for alias, ipt := range ini.GetAll() {
-
-		if alias == "" {
-			continue
-		}
-
-		go report.CrawlIPT(ipt["url"], alias, result)
-		count++
+	ipts, err := report.ReadIPTs(*iniFile)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	for i := 0; i < count; i++ {
-		select {
-		case r := <-result:
-			ipt := report.IPT{Name: r.Name}
-			if r.Err != nil {
-				ipt.Err = r.Err
-			} else {
-				for _, resource := range r.Msg {
-					col := report.Resource{}
-					if err := col.Bind(resource); err != nil {
-						log.Println(err)
-					}
-					ipt.Resources = append(ipt.Resources, col)
-				}
-			}
-			IPTs = append(IPTs, ipt)
+	var IPTs []report.IPT
+	if *stateFile == "" {
+		IPTs = report.Crawl(ipts)
+	} else {
+		state, err := report.ReadCrawlState(*stateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		IPTs = state.Crawl(ipts, *fullEvery, time.Now())
+		if err := state.Write(*stateFile); err != nil {
+			log.Fatal(err)
 		}
 	}
-
-	titles := []string{
-		"IPT",
-		"Resource Name",
-		"Link",
-		"Logo",
-		"Organization",
-		"Type",
-		"Subtype",
-		"Events",
-		"Measurements",
-		"Occurrences",
-		"LastModified",
-		"LastPublication",
-		"NextPublication",
-		"Visibility",
-		"Author",
-		"Error",
+	if *siblings != "" {
+		found := report.FindSiblings(IPTs, ipts)
+		for _, sibling := range found {
+			log.Printf("found IPT %s at %s, missing from %s", sibling.Name, sibling.URL, *iniFile)
+		}
+		if *siblings == "include" && len(found) > 0 {
+			IPTs = append(IPTs, found...)
+			report.SortIPTs(IPTs)
+		}
 	}
-
-	report := csv.NewWriter(os.Stdout)
-
-	if err := report.Write(titles); err != nil {
-		log.Fatal(err)
+	if *snapshotDir != "" {
+		st, err := report.OpenSnapshotStore(*snapshotDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := st.Save(report.NewSnapshot(IPTs, start, time.Since(start))); err != nil {
+			log.Fatal(err)
+		}
 	}
 	for _, ipt := range IPTs {
-		line := make([]string, len(titles))
-		line[0] = ipt.Name
-		if ipt.Err != nil {
-			line[len(titles)-1] = ipt.Err.Error()
-			if err := report.Write(line); err != nil {
-				log.Fatal(err)
-			}
-			continue
-		}
-		for _, resource := range ipt.Resources {
-			line[1] = resource.Name
-			line[2] = resource.Link
-			line[3] = resource.Logo
-			line[4] = resource.Organization
-			line[5] = resource.Type
-			line[6] = resource.Subtype
-
-			line[7] = strconv.Itoa(resource.Events)
-			line[8] = strconv.Itoa(resource.Measurements)
-			line[9] = strconv.Itoa(resource.Occurrences)
-
-			line[10] = resource.LastModified.String()
-			line[11] = resource.LastPublication.String()
-			if resource.NextPublication.After(time.Date(0001, time.January, 1, 0, 0, 0, 0, time.UTC)) {
-				line[12] = resource.NextPublication.String()
-			}
-
-			line[13] = resource.Author
-			line[14] = resource.Visibility
-			line[15] = ""
-			if err := report.Write(line); err != nil {
-				log.Fatal(err)
-			}
-
+		for _, err := range ipt.BindErrs {
+			log.Println(err)
 		}
 	}
 
-	report.Flush()
+	if err := report.WriteReport(rw, IPTs); err != nil {
+		log.Fatal(err)
+	}
 
 }
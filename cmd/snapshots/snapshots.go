@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: snapshots [flags] command
+
+Commands:
+  list           list the snapshots with their IPT and resource counts
+  show [when]    write the resources of the snapshot taken at or before when,
+                 a date like 2018-03-31 or a snapshot id, the latest if empty
+  diff [from [to]]
+                 write the changes between two snapshots, by default the
+                 two latest ones, as -format text, csv or json
+  prune          remove snapshots older than -older, keeping the -keep latest
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	dir := flag.String("dir", "snapshots", "directory of the snapshots saved by report2csv -snapshots")
+	older := flag.Duration("older", 90*24*time.Hour, "age of the snapshots prune removes")
+	keep := flag.Int("keep", 10, "number of latest snapshots prune always keeps")
+	format := flag.String("format", "text", "diff output format: text, csv or json")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	st, err := report.OpenSnapshotStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "list":
+		err = list(st)
+	case "show":
+		err = show(st, flag.Arg(1))
+	case "diff":
+		err = diff(st, flag.Arg(1), flag.Arg(2), *format)
+	case "prune":
+		var removed []string
+		removed, err = st.Prune(time.Now().Add(-*older), *keep)
+		for _, id := range removed {
+			fmt.Println(id)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+}
+
+func list(st *report.SnapshotStore) error {
+	infos, err := st.List()
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(os.Stdout)
+	if err := out.Write([]string{"ID", "Taken", "Elapsed", "IPTs", "Failing IPTs", "Resources", "Occurrences"}); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		s, err := st.Load(info.ID)
+		if err != nil {
+			return err
+		}
+		failing, resources, occurrences := 0, 0, 0
+		for _, ipt := range s.IPTs {
+			if !ipt.Healthy() {
+				failing++
+			}
+			resources += len(ipt.Resources)
+			for _, r := range ipt.Resources {
+				occurrences += r.Occurrences
+			}
+		}
+		line := []string{info.ID, s.Taken.Format(time.RFC3339), s.Elapsed.String(), strconv.Itoa(len(s.IPTs)),
+			strconv.Itoa(failing), strconv.Itoa(resources), strconv.Itoa(occurrences)}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// load loads the snapshot taken at or before when, a date or a snapshot id,
+// or the latest one when empty.
+func load(st *report.SnapshotStore, when string) (*report.Snapshot, error) {
+	if when == "" {
+		return st.Latest()
+	}
+	if t, err := time.Parse("2006-01-02", when); err == nil {
+		return st.At(t.Add(24*time.Hour - time.Second))
+	}
+	return st.Load(when)
+}
+
+func diff(st *report.SnapshotStore, from, to, format string) error {
+	if from == "" {
+		infos, err := st.List()
+		if err != nil {
+			return err
+		}
+		if len(infos) < 2 {
+			return fmt.Errorf("diff needs two snapshots, %s has %d", st.Dir, len(infos))
+		}
+		from = infos[len(infos)-2].ID
+	}
+
+	old, err := load(st, from)
+	if err != nil {
+		return err
+	}
+	cur, err := load(st, to)
+	if err != nil {
+		return err
+	}
+
+	d := report.DiffSnapshots(old, cur)
+	switch format {
+	case "text":
+		return d.WriteText(os.Stdout)
+	case "csv":
+		return d.WriteCSV(os.Stdout)
+	case "json":
+		return d.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("unknown format %s", format)
+}
+
+func show(st *report.SnapshotStore, when string) error {
+	s, err := load(st, when)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(os.Stdout)
+	titles := []string{"IPT", "Resource Name", "Link", "Organization", "Type", "Events", "Measurements", "Occurrences", "LastPublication", "Visibility", "Error"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for _, ipt := range s.IPTs {
+		if !ipt.Healthy() {
+			if err := out.Write([]string{ipt.Name, "", "", "", "", "", "", "", "", "", ipt.Err}); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, r := range ipt.Resources {
+			line := []string{ipt.Name, r.Name, r.Link, r.Organization, r.Type, strconv.Itoa(r.Events), strconv.Itoa(r.Measurements),
+				strconv.Itoa(r.Occurrences), "", r.Visibility, ""}
+			if !r.LastPublication.IsZero() {
+				line[8] = r.LastPublication.Format("2006-01-02")
+			}
+			if err := out.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
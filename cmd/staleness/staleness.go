@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini")
+	window := flag.Duration("window", 365*24*time.Hour, "how long a resource may go without republishing, 0 to skip")
+	format := flag.String("format", "text", "output format, text or csv")
+	notifyFile := flag.String("notify", "", "ini file of the owners to send their own stale resources to, empty to skip")
+
+	flag.Parse()
+
+	if *format != "text" && *format != "csv" {
+		log.Fatalf("unknown format %s", *format)
+	}
+
+	ipts, err := report.ReadIPTs(*iniFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	IPTs := report.Crawl(ipts)
+	for _, ipt := range IPTs {
+		if ipt.Err != nil {
+			log.Printf("%s: %v", ipt.Name, ipt.Err)
+		}
+		for _, err := range ipt.BindErrs {
+			log.Println(err)
+		}
+	}
+
+	now := time.Now()
+	if *notifyFile != "" {
+		notify, err := report.ReadNotifyConfig(*notifyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		write := func(w io.Writer, ipts []report.IPT) error {
+			stale := report.CheckStaleness(ipts, *window, now)
+			if len(stale.Stale) == 0 {
+				return report.ErrNothingToReport
+			}
+			return stale.WriteText(w)
+		}
+		for _, err := range notify.DispatchReport("Stale IPT resources", IPTs, write) {
+			log.Println(err)
+		}
+	}
+
+	stale := report.CheckStaleness(IPTs, *window, now)
+	if *format == "csv" {
+		err = stale.WriteCSV(os.Stdout)
+	} else {
+		err = stale.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+}
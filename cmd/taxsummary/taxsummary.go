@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	dir := flag.String("dir", "archives", "directory with the archives downloaded by verifyarchives")
+	format := flag.String("format", "csv", "output format, csv or json")
+
+	flag.Parse()
+
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("unknown format %s", *format)
+	}
+
+	summary := report.NewTaxonSummary()
+	err := report.WalkArchives(*dir, func(ipt, shortname, path string) error {
+		a, err := report.OpenArchive(path)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			return nil
+		}
+		defer a.Close()
+		if err := summary.AddArchive(ipt, a); err != nil {
+			log.Printf("%s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *format == "json" {
+		err = summary.WriteJSON(os.Stdout)
+	} else {
+		err = summary.WriteCSV(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+}
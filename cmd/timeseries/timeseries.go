@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	dir := flag.String("dir", "snapshots", "directory of the snapshots saved by report2csv -snapshots")
+	level := flag.String("level", report.LevelNational, "aggregation level: resource, ipt, organization or national")
+	interval := flag.String("interval", report.IntervalMonthly, "period of each point: weekly, monthly or yearly")
+	from := flag.String("from", "", "first day, e.g. 2018-01-01, empty for the first snapshot")
+	to := flag.String("to", "", "last day, e.g. 2018-12-31, empty for the latest snapshot")
+	series := flag.String("series", "", "only write the series with this name, e.g. an IPT alias")
+	aliasFile := flag.String("aliases", "", "ini file mapping canonical organization names to their aliases")
+
+	flag.Parse()
+
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		if start, err = time.Parse("2006-01-02", *from); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *to != "" {
+		if end, err = time.Parse("2006-01-02", *to); err != nil {
+			log.Fatal(err)
+		}
+		end = end.Add(24*time.Hour - time.Second)
+	}
+
+	orgs := report.NewOrgNormalizer()
+	if *aliasFile != "" {
+		if orgs, err = report.ReadOrgAliases(*aliasFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	st, err := report.OpenSnapshotStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	snapshots, err := st.Between(start, end)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ts, err := report.BuildTimeSeries(snapshots, *level, *interval, orgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *series != "" {
+		ts.Points = ts.Series(*series)
+	}
+	if err := ts.WriteCSV(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+}
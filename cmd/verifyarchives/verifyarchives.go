@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	iniFile := flag.String("file", "ipts.ini", "path to ipts.ini")
+	stateFile := flag.String("state", "", "state file enabling incremental crawls driven by the IPT RSS feeds")
+	fullEvery := flag.Duration("full", 7*24*time.Hour, "interval between full crawls of incremental runs")
+	dir := flag.String("dir", "archives", "directory keeping the downloaded archives")
+	download := flag.Bool("download", true, "download archives before verifying, otherwise use the ones at -dir")
+
+	flag.Parse()
+
+	ipts, err := report.ReadIPTs(*iniFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	titles := []string{
+		"IPT",
+		"Resource Name",
+		"Archive",
+		"Status",
+		"Records",
+		"Occurrences",
+		"Events",
+		"Measurements",
+		"Archive Core",
+		"Archive Occurrences",
+		"Archive Events",
+		"Archive Measurements",
+		"Discrepancies",
+	}
+
+	out := csv.NewWriter(os.Stdout)
+	if err := out.Write(titles); err != nil {
+		log.Fatal(err)
+	}
+
+	var IPTs []report.IPT
+	if *stateFile == "" {
+		IPTs = report.Crawl(ipts)
+	} else {
+		state, err := report.ReadCrawlState(*stateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		IPTs = state.Crawl(ipts, *fullEvery, time.Now())
+		if err := state.Write(*stateFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, ipt := range IPTs {
+		if ipt.Err != nil {
+			log.Printf("%s: %v", ipt.Name, ipt.Err)
+			continue
+		}
+		for _, err := range ipt.BindErrs {
+			log.Printf("%s: %v", ipt.Name, err)
+		}
+
+		for _, resource := range ipt.Resources {
+			path := report.ArchivePath(*dir, ipt.Name, resource)
+			if path == "" {
+				log.Printf("%s: no shortname for %s", ipt.Name, resource.Name)
+				continue
+			}
+			_, statErr := os.Stat(path)
+			if *download && !(ipt.Unchanged[resource.Shortname()] && statErr == nil) {
+				if err := report.DownloadArchive(resource.ArchiveURL(), path); err != nil {
+					// don't verify an outdated copy of an archive we can't fetch
+					log.Println(err)
+					os.Remove(path)
+				}
+			}
+
+			v := report.VerifyResource(resource, path)
+			line := []string{
+				ipt.Name,
+				resource.Name,
+				path,
+				v.Status,
+				strconv.Itoa(resource.Records),
+				strconv.Itoa(resource.Occurrences),
+				strconv.Itoa(resource.Events),
+				strconv.Itoa(resource.Measurements),
+				strconv.Itoa(v.Counts.Core),
+				strconv.Itoa(v.Counts.Occurrences),
+				strconv.Itoa(v.Counts.Events),
+				strconv.Itoa(v.Counts.Measurements),
+				v.Describe(),
+			}
+			if err := out.Write(line); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Fatal(err)
+	}
+
+}
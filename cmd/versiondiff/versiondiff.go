@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	report "github.com/dvdscripter/iptReport"
+)
+
+func main() {
+
+	link := flag.String("link", "", "resource link, e.g. https://ipt.sibbr.gov.br/peld/resource?r=shortname")
+	from := flag.String("from", "", "older version, e.g. 1.1")
+	to := flag.String("to", "", "newer version, e.g. 1.2")
+	detailsFile := flag.String("details", "", "where to write a csv with every change, empty to skip")
+
+	flag.Parse()
+
+	resource := report.Resource{Link: *link}
+	if resource.ArchiveURL() == "" || *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(resource, *from, *to, *detailsFile); err != nil {
+		log.Fatal(err)
+	}
+
+}
+
+// run compares versions from and to of resource, returning instead of exiting
+// on errors so the downloaded archives are always removed.
+func run(resource report.Resource, from, to, detailsFile string) error {
+	dir, err := ioutil.TempDir("", "versiondiff")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	archives := []*report.Archive{}
+	for i, version := range []string{from, to} {
+		// numbered as -from may equal -to
+		path := filepath.Join(dir, fmt.Sprintf("%d-%s.zip", i, version))
+		if err := report.DownloadArchive(resource.VersionArchiveURL(version), path); err != nil {
+			return err
+		}
+		a, err := report.OpenArchive(path)
+		if err != nil {
+			return fmt.Errorf("version %s: %v", version, err)
+		}
+		defer a.Close()
+		archives = append(archives, a)
+	}
+
+	var details func(report.RecordChange) error
+	var out *csv.Writer
+	if detailsFile != "" {
+		file, err := os.Create(detailsFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		out = csv.NewWriter(file)
+		if err := out.Write([]string{"ID", "Change", "Term", "Old", "New"}); err != nil {
+			return err
+		}
+		details = func(c report.RecordChange) error {
+			return out.Write([]string{c.ID, c.Change, c.Term, c.Old, c.New})
+		}
+	}
+
+	diff, err := report.DiffArchives(archives[0], archives[1], details)
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s %s -> %s\n", resource.Shortname(), from, to)
+	fmt.Printf("added:      %d\n", diff.Added)
+	fmt.Printf("removed:    %d\n", diff.Removed)
+	fmt.Printf("modified:   %d\n", diff.Modified)
+	fmt.Printf("unchanged:  %d\n", diff.Unchanged)
+	fmt.Printf("duplicates: %d\n", diff.Duplicates)
+	return nil
+}
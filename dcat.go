@@ -0,0 +1,463 @@
+package iptReport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Namespaces used by DCAT catalogues.
+const (
+	nsDCAT = "http://www.w3.org/ns/dcat#"
+	nsDCT  = "http://purl.org/dc/terms/"
+	nsFOAF = "http://xmlns.com/foaf/0.1/"
+	nsXSD  = "http://www.w3.org/2001/XMLSchema#"
+	nsRDF  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+var dcatPrefixes = [][2]string{
+	{"dcat", nsDCAT},
+	{"dct", nsDCT},
+	{"foaf", nsFOAF},
+	{"xsd", nsXSD},
+	{"rdf", nsRDF},
+}
+
+// DCATText is a text literal of a DCAT catalogue with its language tag, empty
+// when untagged.
+type DCATText struct {
+	Value string
+	Lang  string
+}
+
+// DCATCatalog is a DCAT catalogue as published by an IPT at /dcat. Dates are
+// kept as published, usually as xsd:date. Publisher is the organization name
+// when given, otherwise its IRI.
+type DCATCatalog struct {
+	URI         string
+	Title       DCATText
+	Description DCATText
+	Homepage    string
+	Publisher   string
+	Language    string
+	License     string
+	Issued      string
+	Modified    string
+	Datasets    []DCATDataset
+}
+
+// DCATDataset is a dataset of a DCATCatalog.
+type DCATDataset struct {
+	URI           string
+	Title         DCATText
+	Description   DCATText
+	Identifier    string
+	LandingPage   string
+	Publisher     string
+	Keywords      []DCATText
+	Issued        string
+	Modified      string
+	Distributions []DCATDistribution
+}
+
+// DCATDistribution is a downloadable form of a DCATDataset.
+type DCATDistribution struct {
+	URI         string
+	Title       DCATText
+	Format      string
+	MediaType   string
+	DownloadURL string
+	AccessURL   string
+	License     string
+}
+
+// DCATURL returns the address of the DCAT catalogue of the IPT at iptURL.
+func DCATURL(iptURL string) string {
+	return strings.TrimRight(iptURL, "/") + "/dcat"
+}
+
+// rdfGraph indexes triples by subject, keeping their order.
+type rdfGraph struct {
+	subjects []Term
+	triples  map[Term][]Triple
+}
+
+func newGraph(triples []Triple) *rdfGraph {
+	g := &rdfGraph{triples: map[Term][]Triple{}}
+	for _, t := range triples {
+		if _, ok := g.triples[t.Subject]; !ok {
+			g.subjects = append(g.subjects, t.Subject)
+		}
+		g.triples[t.Subject] = append(g.triples[t.Subject], t)
+	}
+	return g
+}
+
+// objects returns every object of subject through predicate.
+func (g *rdfGraph) objects(subject Term, predicate string) []Term {
+	objects := []Term{}
+	for _, t := range g.triples[subject] {
+		if t.Predicate.Value == predicate {
+			objects = append(objects, t.Object)
+		}
+	}
+	return objects
+}
+
+// value returns the first object of subject through predicate, empty if
+// absent.
+func (g *rdfGraph) value(subject Term, predicate string) string {
+	for _, t := range g.triples[subject] {
+		if t.Predicate.Value == predicate {
+			return t.Object.Value
+		}
+	}
+	return ""
+}
+
+// text returns the first object of subject through predicate with its
+// language tag.
+func (g *rdfGraph) text(subject Term, predicate string) DCATText {
+	for _, t := range g.triples[subject] {
+		if t.Predicate.Value == predicate {
+			return DCATText{Value: t.Object.Value, Lang: t.Object.Lang}
+		}
+	}
+	return DCATText{}
+}
+
+// name returns the foaf:name of an agent, or its IRI.
+func (g *rdfGraph) name(subject Term, predicate string) string {
+	for _, agent := range g.objects(subject, predicate) {
+		if name := g.value(agent, nsFOAF+"name"); name != "" {
+			return name
+		}
+		if agent.Kind == TermIRI {
+			return agent.Value
+		}
+	}
+	return ""
+}
+
+// ofType returns the subjects having rdf:type typ.
+func (g *rdfGraph) ofType(typ string) []Term {
+	subjects := []Term{}
+	for _, s := range g.subjects {
+		for _, t := range g.objects(s, rdfType) {
+			if t.Value == typ {
+				subjects = append(subjects, s)
+				break
+			}
+		}
+	}
+	return subjects
+}
+
+// ParseDCAT reads a DCAT catalogue in Turtle.
+func ParseDCAT(r io.Reader) (*DCATCatalog, error) {
+	triples, err := ParseTurtle(r)
+	if err != nil {
+		return nil, err
+	}
+	g := newGraph(triples)
+
+	catalogs := g.ofType(nsDCAT + "Catalog")
+	if len(catalogs) == 0 {
+		return nil, fmt.Errorf("No dcat:Catalog found")
+	}
+	c := catalogs[0]
+	catalog := &DCATCatalog{
+		URI:         c.Value,
+		Title:       g.text(c, nsDCT+"title"),
+		Description: g.text(c, nsDCT+"description"),
+		Homepage:    g.value(c, nsFOAF+"homepage"),
+		Publisher:   g.name(c, nsDCT+"publisher"),
+		Language:    g.value(c, nsDCT+"language"),
+		License:     g.value(c, nsDCT+"license"),
+		Issued:      g.value(c, nsDCT+"issued"),
+		Modified:    g.value(c, nsDCT+"modified"),
+	}
+
+	// datasets listed by the catalogue first, then any other
+	datasets := g.objects(c, nsDCAT+"dataset")
+	listed := map[Term]bool{}
+	for _, d := range datasets {
+		listed[d] = true
+	}
+	for _, d := range g.ofType(nsDCAT + "Dataset") {
+		if !listed[d] {
+			datasets = append(datasets, d)
+		}
+	}
+
+	for _, d := range datasets {
+		dataset := DCATDataset{
+			URI:         d.Value,
+			Title:       g.text(d, nsDCT+"title"),
+			Description: g.text(d, nsDCT+"description"),
+			Identifier:  g.value(d, nsDCT+"identifier"),
+			LandingPage: g.value(d, nsDCAT+"landingPage"),
+			Publisher:   g.name(d, nsDCT+"publisher"),
+			Issued:      g.value(d, nsDCT+"issued"),
+			Modified:    g.value(d, nsDCT+"modified"),
+		}
+		for _, k := range g.objects(d, nsDCAT+"keyword") {
+			dataset.Keywords = append(dataset.Keywords, DCATText{Value: k.Value, Lang: k.Lang})
+		}
+		for _, dist := range g.objects(d, nsDCAT+"distribution") {
+			dataset.Distributions = append(dataset.Distributions, DCATDistribution{
+				URI:         dist.Value,
+				Title:       g.text(dist, nsDCT+"title"),
+				Format:      g.value(dist, nsDCT+"format"),
+				MediaType:   g.value(dist, nsDCAT+"mediaType"),
+				DownloadURL: g.value(dist, nsDCAT+"downloadURL"),
+				AccessURL:   g.value(dist, nsDCAT+"accessURL"),
+				License:     g.value(dist, nsDCT+"license"),
+			})
+		}
+		catalog.Datasets = append(catalog.Datasets, dataset)
+	}
+
+	return catalog, nil
+}
+
+// FetchDCAT reads the DCAT catalogue at url.
+func FetchDCAT(url string) (*DCATCatalog, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching %s: %s", url, resp.Status)
+	}
+
+	return ParseDCAT(resp.Body)
+}
+
+// MergeCatalogs joins the datasets of catalogs into a single catalogue
+// identified by uri, keeping the first dataset of each URI.
+func MergeCatalogs(uri, title string, catalogs ...*DCATCatalog) *DCATCatalog {
+	merged := &DCATCatalog{URI: uri, Title: DCATText{Value: title}}
+	seen := map[string]bool{}
+	for _, c := range catalogs {
+		for _, d := range c.Datasets {
+			if d.URI != "" && seen[d.URI] {
+				continue
+			}
+			seen[d.URI] = true
+			merged.Datasets = append(merged.Datasets, d)
+		}
+	}
+	return merged
+}
+
+// CatalogFromIPTs builds a catalogue identified by uri from crawled IPTs, one
+// dataset per resource with its DwC-A as distribution.
+func CatalogFromIPTs(uri, title string, ipts []IPT) *DCATCatalog {
+	catalog := &DCATCatalog{URI: uri, Title: DCATText{Value: title}}
+	for _, ipt := range ipts {
+		for _, r := range ipt.Resources {
+			if r.Link == "" {
+				continue
+			}
+			d := DCATDataset{
+				URI:         r.Link,
+				Title:       DCATText{Value: r.Name},
+				Identifier:  r.Shortname(),
+				LandingPage: r.Link,
+			}
+			if r.Organization != NotRegistered {
+				d.Publisher = r.Organization
+			}
+			if !r.LastPublication.IsZero() {
+				d.Issued = r.LastPublication.Format("2006-01-02")
+			}
+			if !r.LastModified.IsZero() {
+				d.Modified = r.LastModified.Format("2006-01-02")
+			}
+			if archive := r.ArchiveURL(); archive != "" {
+				d.Distributions = []DCATDistribution{{
+					URI:         archive,
+					Title:       DCATText{Value: "Darwin Core Archive"},
+					Format:      "DwC-A",
+					MediaType:   "application/zip",
+					DownloadURL: archive,
+				}}
+			}
+			catalog.Datasets = append(catalog.Datasets, d)
+		}
+	}
+	return catalog
+}
+
+// statements collects triples of a single subject, skipping empty values.
+type statements struct {
+	subject Term
+	triples []Triple
+}
+
+func (s *statements) add(predicate string, object Term) {
+	if object.Value == "" {
+		return
+	}
+	s.triples = append(s.triples, Triple{s.subject, Term{Kind: TermIRI, Value: predicate}, object})
+}
+
+func (s *statements) iri(predicate, value string) {
+	s.add(predicate, Term{Kind: TermIRI, Value: value})
+}
+
+func (s *statements) literal(predicate, value string) {
+	s.add(predicate, Term{Kind: TermLiteral, Value: value})
+}
+
+func (s *statements) text(predicate string, value DCATText) {
+	s.add(predicate, Term{Kind: TermLiteral, Value: value.Value, Lang: value.Lang})
+}
+
+func (s *statements) date(predicate, value string) {
+	s.add(predicate, Term{Kind: TermLiteral, Value: value, Datatype: nsXSD + "date"})
+}
+
+// graph returns the catalogue as triples grouped by subject. Publishers are
+// written as blank nodes named with foaf:name unless they are IRIs.
+func (c *DCATCatalog) graph() [][]Triple {
+	groups := [][]Triple{}
+	blanks := 0
+	publisher := func(s *statements, name string) {
+		if name == "" {
+			return
+		}
+		if strings.Contains(name, "://") {
+			s.iri(nsDCT+"publisher", name)
+			return
+		}
+		blanks++
+		agent := &statements{subject: Term{Kind: TermBlank, Value: fmt.Sprintf("publisher%d", blanks)}}
+		agent.iri(rdfType, nsFOAF+"Organization")
+		agent.literal(nsFOAF+"name", name)
+		s.add(nsDCT+"publisher", agent.subject)
+		groups = append(groups, agent.triples)
+	}
+
+	catalog := &statements{subject: Term{Kind: TermIRI, Value: c.URI}}
+	catalog.iri(rdfType, nsDCAT+"Catalog")
+	catalog.text(nsDCT+"title", c.Title)
+	catalog.text(nsDCT+"description", c.Description)
+	catalog.iri(nsFOAF+"homepage", c.Homepage)
+	publisher(catalog, c.Publisher)
+	catalog.iri(nsDCT+"language", c.Language)
+	catalog.iri(nsDCT+"license", c.License)
+	catalog.date(nsDCT+"issued", c.Issued)
+	catalog.date(nsDCT+"modified", c.Modified)
+	for _, d := range c.Datasets {
+		catalog.iri(nsDCAT+"dataset", d.URI)
+	}
+	groups = append([][]Triple{catalog.triples}, groups...)
+
+	for _, d := range c.Datasets {
+		dataset := &statements{subject: Term{Kind: TermIRI, Value: d.URI}}
+		dataset.iri(rdfType, nsDCAT+"Dataset")
+		dataset.text(nsDCT+"title", d.Title)
+		dataset.text(nsDCT+"description", d.Description)
+		dataset.literal(nsDCT+"identifier", d.Identifier)
+		dataset.iri(nsDCAT+"landingPage", d.LandingPage)
+		publisher(dataset, d.Publisher)
+		for _, k := range d.Keywords {
+			dataset.text(nsDCAT+"keyword", k)
+		}
+		dataset.date(nsDCT+"issued", d.Issued)
+		dataset.date(nsDCT+"modified", d.Modified)
+		for _, dist := range d.Distributions {
+			dataset.iri(nsDCAT+"distribution", dist.URI)
+		}
+		groups = append(groups, dataset.triples)
+
+		for _, dist := range d.Distributions {
+			distribution := &statements{subject: Term{Kind: TermIRI, Value: dist.URI}}
+			distribution.iri(rdfType, nsDCAT+"Distribution")
+			distribution.text(nsDCT+"title", dist.Title)
+			distribution.literal(nsDCT+"format", dist.Format)
+			distribution.literal(nsDCAT+"mediaType", dist.MediaType)
+			distribution.iri(nsDCAT+"downloadURL", dist.DownloadURL)
+			distribution.iri(nsDCAT+"accessURL", dist.AccessURL)
+			distribution.iri(nsDCT+"license", dist.License)
+			groups = append(groups, distribution.triples)
+		}
+	}
+
+	return groups
+}
+
+// WriteTurtle writes the catalogue as Turtle.
+func (c *DCATCatalog) WriteTurtle(w io.Writer) error {
+	tw := newTurtleWriter(w, dcatPrefixes)
+	for _, group := range c.graph() {
+		tw.subject(group)
+	}
+	return tw.flush()
+}
+
+// WriteJSONLD writes the catalogue as a JSON-LD document with one node per
+// subject at @graph.
+func (c *DCATCatalog) WriteJSONLD(w io.Writer) error {
+	context := map[string]string{}
+	for _, prefix := range dcatPrefixes {
+		context[prefix[0]] = prefix[1]
+	}
+	compact := func(iri string) string {
+		for _, prefix := range dcatPrefixes {
+			if local := strings.TrimPrefix(iri, prefix[1]); local != iri {
+				return prefix[0] + ":" + local
+			}
+		}
+		return iri
+	}
+	id := func(t Term) string {
+		if t.Kind == TermBlank {
+			return "_:" + t.Value
+		}
+		return t.Value
+	}
+
+	graph := []map[string]interface{}{}
+	for _, group := range c.graph() {
+		node := map[string]interface{}{"@id": id(group[0].Subject)}
+		for _, t := range group {
+			if t.Predicate.Value == rdfType {
+				node["@type"] = compact(t.Object.Value)
+				continue
+			}
+			var value interface{}
+			switch {
+			case t.Object.Kind != TermLiteral:
+				value = map[string]string{"@id": id(t.Object)}
+			case t.Object.Lang != "":
+				value = map[string]string{"@value": t.Object.Value, "@language": t.Object.Lang}
+			case t.Object.Datatype != "":
+				value = map[string]string{"@value": t.Object.Value, "@type": compact(t.Object.Datatype)}
+			default:
+				value = t.Object.Value
+			}
+
+			key := compact(t.Predicate.Value)
+			switch prev := node[key].(type) {
+			case nil:
+				node[key] = value
+			case []interface{}:
+				node[key] = append(prev, value)
+			default:
+				node[key] = []interface{}{prev, value}
+			}
+		}
+		graph = append(graph, node)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{"@context": context, "@graph": graph})
+}
@@ -0,0 +1,137 @@
+package iptReport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchDCAT(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/peld/dcat" {
+			http.NotFound(w, r)
+			return
+		}
+		file, _ := os.Open("testdata/dcat/catalog.ttl")
+		defer file.Close()
+		io.Copy(w, file)
+	}))
+	defer ts.Close()
+
+	const resource = "https://ipt.sibbr.gov.br/peld/resource?r=diversidade_de_macroinvertebrados_bentonicos_peld"
+	const archive = "https://ipt.sibbr.gov.br/peld/archive.do?r=diversidade_de_macroinvertebrados_bentonicos_peld"
+	want := &DCATCatalog{
+		URI:         "https://ipt.sibbr.gov.br/peld/dcat",
+		Title:       DCATText{Value: "IPT PELD"},
+		Description: DCATText{Value: "Integrated Publishing Toolkit of the\n\"PELD\" program"},
+		Homepage:    "https://ipt.sibbr.gov.br/peld",
+		Publisher:   "SiBBr",
+		Language:    "http://id.loc.gov/vocabulary/iso639-1/en",
+		Issued:      "2017-09-12",
+		Datasets: []DCATDataset{{
+			URI:         resource + "#Dataset",
+			Title:       DCATText{"Diversidade de macroinvertebrados bentônicos", "pt"},
+			Description: DCATText{Value: "Macroinvertebrados do \"PELD\""},
+			LandingPage: resource,
+			Keywords:    []DCATText{{Value: "Samplingevent"}, {"benthos", "en"}},
+			Issued:      "2017-11-10",
+			Modified:    "2017-11-10",
+			Distributions: []DCATDistribution{{
+				URI:         archive + "#Distribution",
+				Format:      "dwc-a",
+				MediaType:   "application/zip",
+				DownloadURL: archive,
+				License:     "http://creativecommons.org/licenses/by/4.0/legalcode",
+			}},
+		}},
+	}
+
+	tableCases := []struct {
+		input       string
+		output      *DCATCatalog
+		shouldError bool
+	}{
+		{DCATURL(ts.URL + "/peld/"), want, false},
+		{ts.URL + "/missing/dcat", nil, true},
+		{"THIS URL SHOULDN'T EXIST", nil, true},
+	}
+
+	for _, tt := range tableCases {
+		c, err := FetchDCAT(tt.input)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error fetching %s", tt.input)
+		} else if !reflect.DeepEqual(c, tt.output) && tt.shouldError == false {
+			t.Errorf("got \n%#v, want \n%#v", c, tt.output)
+		}
+	}
+}
+
+func TestCatalogExport(t *testing.T) {
+	file, err := os.Open("testdata/dcat/catalog.ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	peld, err := ParseDCAT(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crawled := CatalogFromIPTs("", "", []IPT{{
+		Name: "repatriados",
+		Resources: []Resource{
+			{
+				Name:            "Repatriation Data for SiBBr",
+				Link:            "https://ipt.sibbr.gov.br/repatriados/resource?r=repatriados",
+				Organization:    "Not registered",
+				LastPublication: time.Date(2017, time.August, 7, 0, 0, 0, 0, time.UTC),
+			},
+			{Name: "Without link"},
+		},
+	}})
+	merged := MergeCatalogs("https://example.org/dcat", "National catalogue", peld, crawled, peld)
+	if len(merged.Datasets) != 2 {
+		t.Fatalf("got \n%#v", merged.Datasets)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := merged.WriteTurtle(buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ParseDCAT(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, merged) {
+		t.Errorf("got \n%#v, want \n%#v", read, merged)
+	}
+	if d := read.Datasets[1]; d.Issued != "2017-08-07" || d.Publisher != "" ||
+		d.Distributions[0].DownloadURL != "https://ipt.sibbr.gov.br/repatriados/archive.do?r=repatriados" {
+		t.Errorf("got \n%#v", d)
+	}
+
+	buf.Reset()
+	if err := merged.WriteJSONLD(buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := struct {
+		Context map[string]string        `json:"@context"`
+		Graph   []map[string]interface{} `json:"@graph"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	title, _ := doc.Graph[1]["dct:title"].(map[string]interface{})
+	if doc.Context["dcat"] != nsDCAT || len(doc.Graph) != 5 || doc.Graph[0]["@type"] != "dcat:Catalog" ||
+		title["@language"] != "pt" {
+		t.Errorf("got \n%s", buf.String())
+	}
+}
@@ -0,0 +1,154 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResourceRef identifies an archived resource by IPT alias and shortname,
+// and by its ResourceKey when known.
+type ResourceRef struct {
+	IPT      string
+	Resource string
+	Key      ResourceKey
+}
+
+// Overlap counts the records two resources share, by occurrenceID and by
+// institutionCode plus catalogNumber.
+type Overlap struct {
+	A, B           ResourceRef
+	OccurrenceIDs  int
+	CatalogNumbers int
+}
+
+// Deduplicator indexes occurrence identifiers of many archives to find
+// records published more than once across resources and IPTs.
+type Deduplicator struct {
+	resources []ResourceRef
+	keys      map[ResourceKey]bool
+	ids       map[string][]int
+	catalog   map[string][]int
+}
+
+// NewDeduplicator returns an empty Deduplicator.
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{keys: map[ResourceKey]bool{}, ids: map[string][]int{}, catalog: map[string][]int{}}
+}
+
+// index appends resource to the resources holding key, once.
+func index(m map[string][]int, key string, resource int) {
+	refs := m[key]
+	if len(refs) > 0 && refs[len(refs)-1] == resource {
+		return
+	}
+	m[key] = append(refs, resource)
+}
+
+// AddArchive indexes every occurrence of the archive of resource ref.
+// Occurrences at the core without an occurrenceID use the core id. Archives
+// of a ref.Key already added, e.g. an IPT listed under two aliases, are
+// skipped.
+func (d *Deduplicator) AddArchive(ref ResourceRef, a *Archive) error {
+	if ref.Key != "" {
+		if d.keys[ref.Key] {
+			return nil
+		}
+		d.keys[ref.Key] = true
+	}
+	resource := len(d.resources)
+	d.resources = append(d.resources, ref)
+	coreOccurrence := a.Core.IsRowType(RowOccurrence)
+
+	return a.EachOccurrence(func(rec Record) error {
+		id := strings.TrimSpace(rec.Get("occurrenceID"))
+		if id == "" && coreOccurrence {
+			id = strings.TrimSpace(rec.ID)
+		}
+		if id != "" {
+			index(d.ids, id, resource)
+		}
+
+		// bare catalog numbers are only unique within a collection
+		catalog := strings.TrimSpace(rec.Get("catalogNumber"))
+		institution := strings.ToLower(strings.TrimSpace(rec.Get("institutionCode")))
+		if catalog != "" && institution != "" {
+			index(d.catalog, institution+"\x00"+catalog, resource)
+		}
+		return nil
+	})
+}
+
+// Overlaps returns every pair of resources sharing records, the ones sharing
+// the most first.
+func (d *Deduplicator) Overlaps() []Overlap {
+	type pair struct{ a, b int }
+	pairs := map[pair]*Overlap{}
+
+	count := func(m map[string][]int, occurrenceID bool) {
+		for _, refs := range m {
+			for i := 0; i < len(refs); i++ {
+				for j := i + 1; j < len(refs); j++ {
+					p := pair{refs[i], refs[j]}
+					o, ok := pairs[p]
+					if !ok {
+						o = &Overlap{A: d.resources[p.a], B: d.resources[p.b]}
+						pairs[p] = o
+					}
+					if occurrenceID {
+						o.OccurrenceIDs++
+					} else {
+						o.CatalogNumbers++
+					}
+				}
+			}
+		}
+	}
+	count(d.ids, true)
+	count(d.catalog, false)
+
+	overlaps := make([]Overlap, 0, len(pairs))
+	for _, o := range pairs {
+		overlaps = append(overlaps, *o)
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		a, b := overlaps[i], overlaps[j]
+		if na, nb := maxInt(a.OccurrenceIDs, a.CatalogNumbers), maxInt(b.OccurrenceIDs, b.CatalogNumbers); na != nb {
+			return na > nb
+		}
+		if a.A != b.A {
+			return a.A.IPT < b.A.IPT || a.A.IPT == b.A.IPT && a.A.Resource < b.A.Resource
+		}
+		return a.B.IPT < b.B.IPT || a.B.IPT == b.B.IPT && a.B.Resource < b.B.Resource
+	})
+
+	return overlaps
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// WriteCSV writes the overlaps, one resource pair per line.
+func (d *Deduplicator) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"IPT A", "Resource A", "IPT B", "Resource B", "Shared occurrenceID", "Shared catalogNumber"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for _, o := range d.Overlaps() {
+		line := []string{o.A.IPT, o.A.Resource, o.B.IPT, o.B.Resource, strconv.Itoa(o.OccurrenceIDs), strconv.Itoa(o.CatalogNumbers)}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,101 @@
+package iptReport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDeduplicator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ref := func(ipt, resource string) ResourceRef {
+		return ResourceRef{IPT: ipt, Resource: resource, Key: NewResourceKey("https://ipt.example.org/"+ipt, resource)}
+	}
+	archives := []struct {
+		ref  ResourceRef
+		rows []string
+	}{
+		{
+			ref("repatriados", "repatriados"),
+			[]string{
+				"urn:1\t\t\t\t\t\t\t\t\tINPA\t10",
+				"urn:2\t\t\t\t\t\t\t\t\tINPA\t11",
+				"urn:3\t\t\t\t\t\t\t\t\tMPEG\t7",
+				"urn:3\t\t\t\t\t\t\t\t\tMPEG\t7",
+			},
+		},
+		{
+			ref("inpa", "aves"),
+			[]string{
+				"urn:1\t\t\t\t\t\t\t\t\tinpa \t10",
+				"inpa:2\t\t\t\t\t\t\t\t\tINPA\t11",
+			},
+		},
+		{
+			ref("goeldi", "mamiferos"),
+			[]string{
+				"urn:3\t\t\t\t\t\t\t\t\tMPEG\t",
+			},
+		},
+		{
+			ref("herbario", "exsicatas"),
+			[]string{
+				"herb:1\t\t\t\t\t\t\t\t\t\t1",
+				"herb:2\t\t\t\t\t\t\t\t\t\t2",
+			},
+		},
+		{
+			ref("peld", "peixes"),
+			[]string{
+				"peld:1\t\t\t\t\t\t\t\t\t\t1",
+				"peld:2\t\t\t\t\t\t\t\t\t\t2",
+			},
+		},
+		{
+			// the inpa IPT listed again under another alias
+			ResourceRef{IPT: "inpa-mirror", Resource: "aves", Key: NewResourceKey("http://ipt.example.org/inpa/", "aves")},
+			[]string{
+				"urn:1\t\t\t\t\t\t\t\t\tinpa \t10",
+				"inpa:2\t\t\t\t\t\t\t\t\tINPA\t11",
+			},
+		},
+	}
+
+	d := NewDeduplicator()
+	for _, archive := range archives {
+		a, err := OpenArchive(writeArchive(t, dir, archive.ref.IPT+"-"+archive.ref.Resource+".zip", testOccurrenceArchive(archive.rows...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.AddArchive(archive.ref, a); err != nil {
+			t.Fatal(err)
+		}
+		a.Close()
+	}
+
+	want := []Overlap{
+		{ref("repatriados", "repatriados"), ref("inpa", "aves"), 1, 2},
+		{ref("repatriados", "repatriados"), ref("goeldi", "mamiferos"), 1, 0},
+	}
+	if got := d.Overlaps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got \n%#v, want \n%#v", got, want)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := d.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	csv := "IPT A,Resource A,IPT B,Resource B,Shared occurrenceID,Shared catalogNumber\n" +
+		"repatriados,repatriados,inpa,aves,1,2\n" +
+		"repatriados,repatriados,goeldi,mamiferos,1,0\n"
+	if buf.String() != csv {
+		t.Errorf("got \n%s, want \n%s", buf.String(), csv)
+	}
+}
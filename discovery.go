@@ -0,0 +1,261 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/zieckey/goini"
+)
+
+// InstallationIPT is the installation type of IPTs at the GBIF registry.
+const InstallationIPT = "IPT_INSTALLATION"
+
+// InstallationFilter selects installations of publishers from Country, an ISO
+// 3166 code, or endorsed by the node with key Node. Type keeps only
+// installations of a type, all when empty.
+type InstallationFilter struct {
+	Country string
+	Node    string
+	Type    string
+}
+
+// InstallationLister is a registry able to list installations, such as
+// GBIFRegistry.
+type InstallationLister interface {
+	Installations(filter InstallationFilter) ([]Installation, error)
+}
+
+// installations fetches every installation listed at path.
+func (g *GBIFRegistry) installations(path string, query url.Values) ([]Installation, error) {
+	installations := []Installation{}
+	err := g.paged(path, query, func(results json.RawMessage) (int, error) {
+		page := []gbifInstallation{}
+		if err := json.Unmarshal(results, &page); err != nil {
+			return 0, err
+		}
+		for _, i := range page {
+			installations = append(installations, i.installation())
+		}
+		return len(page), nil
+	})
+	return installations, err
+}
+
+// Installations lists the installations endorsed by filter.Node, or hosted
+// by organizations of filter.Country, or every one when both are empty.
+func (g *GBIFRegistry) Installations(filter InstallationFilter) ([]Installation, error) {
+	var installations []Installation
+	var err error
+
+	switch {
+	case filter.Node != "":
+		installations, err = g.installations("/node/"+filter.Node+"/installation", nil)
+	case filter.Country != "":
+		organizations := []string{}
+		query := url.Values{"country": {strings.ToUpper(filter.Country)}}
+		err = g.paged("/organization", query, func(results json.RawMessage) (int, error) {
+			page := []struct {
+				Key string `json:"key"`
+			}{}
+			if err := json.Unmarshal(results, &page); err != nil {
+				return 0, err
+			}
+			for _, o := range page {
+				organizations = append(organizations, o.Key)
+			}
+			return len(page), nil
+		})
+		for _, o := range organizations {
+			if err != nil {
+				break
+			}
+			var hosted []Installation
+			hosted, err = g.installations("/organization/"+o+"/installation", nil)
+			installations = append(installations, hosted...)
+		}
+	default:
+		query := url.Values{}
+		if filter.Type != "" {
+			query.Set("type", filter.Type)
+		}
+		installations, err = g.installations("/installation", query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Type == "" {
+		return installations, nil
+	}
+	typed := installations[:0]
+	for _, i := range installations {
+		if i.Type == filter.Type {
+			typed = append(typed, i)
+		}
+	}
+	return typed, nil
+}
+
+// BaseURL returns the address of the IPT serving the installation, found
+// removing the page, like rss.do, from its first endpoint.
+func (i Installation) BaseURL() string {
+	for _, endpoint := range i.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		u.RawQuery, u.Fragment = "", ""
+		if strings.HasSuffix(u.Path, ".do") {
+			u.Path = u.Path[:strings.LastIndex(u.Path, "/")+1]
+		}
+		return strings.TrimRight(u.String(), "/")
+	}
+	return ""
+}
+
+// normalizeURL drops the scheme and trailing slashes of an IPT address so
+// http and https entries compare equal.
+func normalizeURL(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+	return strings.ToLower(strings.TrimRight(u, "/"))
+}
+
+// aliasFor returns a short alias for the IPT at base: the last path segment
+// other than "ipt", or else the first label of the host other than "ipt" or
+// "www".
+func aliasFor(base string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if s := strings.ToLower(segments[i]); s != "" && s != "ipt" {
+			return s
+		}
+	}
+	for _, label := range strings.Split(strings.ToLower(u.Hostname()), ".") {
+		if label != "ipt" && label != "www" {
+			return label
+		}
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Discovery is the result of merging discovered installations into a list of
+// IPTs. Added holds the aliases of new IPTs and Removed the ones of IPTs the
+// registry no longer lists.
+type Discovery struct {
+	IPTs    map[string]string
+	Added   []string
+	Removed []string
+}
+
+// IPTSourceRegistry is the source of the IPTs Discover adds, marked in
+// ipts.ini as source=registry. IPTs listed by hand have no source.
+const IPTSourceRegistry = "registry"
+
+// ReadIPTSources reads the source of every IPT of an ipts.ini file, by alias,
+// empty for IPTs listed by hand.
+func ReadIPTSources(path string) (map[string]string, error) {
+	ini := goini.New()
+	if err := ini.ParseFile(path); err != nil {
+		return nil, err
+	}
+
+	sources := map[string]string{}
+	for alias, kv := range ini.GetAll() {
+		if alias != goini.DefaultSection {
+			sources[alias] = kv["source"]
+		}
+	}
+	return sources, nil
+}
+
+// Discover merges the IPT installations into previous, a map of alias to url
+// as read by ReadIPTs, whose sources are read by ReadIPTSources. Known IPTs
+// keep their alias, new ones get a unique one from their url. IPTs from the
+// registry missing from installations are reported as removed, and dropped
+// from the list when prune is true, while the ones listed by hand are kept.
+func Discover(installations []Installation, previous, sources map[string]string, prune bool) Discovery {
+	d := Discovery{IPTs: map[string]string{}}
+	known := map[string]string{}
+	for alias, u := range previous {
+		known[normalizeURL(u)] = alias
+		d.IPTs[alias] = u
+	}
+
+	listed := map[string]bool{}
+	sort.Slice(installations, func(i, j int) bool { return installations[i].BaseURL() < installations[j].BaseURL() })
+	for _, i := range installations {
+		base := i.BaseURL()
+		if base == "" || listed[normalizeURL(base)] {
+			continue
+		}
+		listed[normalizeURL(base)] = true
+		if _, ok := known[normalizeURL(base)]; ok {
+			continue
+		}
+
+		alias := aliasFor(base)
+		for n := 2; d.IPTs[alias] != ""; n++ {
+			alias = fmt.Sprintf("%s-%d", aliasFor(base), n)
+		}
+		d.IPTs[alias] = base
+		d.Added = append(d.Added, alias)
+	}
+
+	for u, alias := range known {
+		if !listed[u] && sources[alias] == IPTSourceRegistry {
+			d.Removed = append(d.Removed, alias)
+			if prune {
+				delete(d.IPTs, alias)
+			}
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+
+	return d
+}
+
+// UpdateIPTs writes previous, the contents of an ipts.ini file, updated by
+// d: sections of IPTs d dropped are removed and the IPTs it added appended,
+// marked with their source, leaving comments and other entries untouched.
+func UpdateIPTs(w io.Writer, previous io.Reader, d Discovery) error {
+	buf := bufio.NewWriter(w)
+	written := false
+	if previous != nil {
+		scanner := bufio.NewScanner(previous)
+		keep := true
+		for scanner.Scan() {
+			line := scanner.Text()
+			if t := strings.TrimSpace(line); strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") {
+				_, keep = d.IPTs[strings.TrimSpace(t[1:len(t)-1])]
+			}
+			if keep {
+				fmt.Fprintln(buf, line)
+				written = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, alias := range d.Added {
+		if written {
+			fmt.Fprintln(buf)
+		}
+		fmt.Fprintf(buf, "[%s]\nurl=%s\nsource=%s\n", alias, d.IPTs[alias], IPTSourceRegistry)
+		written = true
+	}
+	return buf.Flush()
+}
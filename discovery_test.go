@@ -0,0 +1,182 @@
+package iptReport
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstallations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/node/n1/installation":
+			fmt.Fprint(w, `{"endOfRecords":true,"results":[
+				{"key":"i1","type":"IPT_INSTALLATION","endpoints":[{"url":"https://ipt.example.org/peld/rss.do"}]},
+				{"key":"h1","type":"HTTP_INSTALLATION","endpoints":[{"url":"https://example.org/tapir"}]}]}`)
+		case "/organization":
+			if r.URL.Query().Get("country") != "BR" {
+				fmt.Fprint(w, `{"endOfRecords":true,"results":[]}`)
+				return
+			}
+			fmt.Fprint(w, `{"endOfRecords":true,"results":[{"key":"o1"},{"key":"o2"}]}`)
+		case "/organization/o1/installation":
+			fmt.Fprint(w, `{"endOfRecords":true,"results":[{"key":"i1","type":"IPT_INSTALLATION","endpoints":[{"url":"https://ipt.example.org/peld/rss.do"}]}]}`)
+		case "/organization/o2/installation":
+			fmt.Fprint(w, `{"endOfRecords":true,"results":[{"key":"i2","type":"IPT_INSTALLATION","endpoints":[{"url":"http://ipt.other.org/"}]}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	reg := NewGBIFRegistry(ts.URL)
+
+	tableCases := []struct {
+		input       InstallationFilter
+		output      []string
+		shouldError bool
+	}{
+		{InstallationFilter{Node: "n1"}, []string{"i1", "h1"}, false},
+		{InstallationFilter{Node: "n1", Type: InstallationIPT}, []string{"i1"}, false},
+		{InstallationFilter{Country: "br", Type: InstallationIPT}, []string{"i1", "i2"}, false},
+		{InstallationFilter{Node: "missing"}, nil, true},
+	}
+
+	for _, tt := range tableCases {
+		installations, err := reg.Installations(tt.input)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error listing %#v", tt.input)
+		}
+		var keys []string
+		for _, i := range installations {
+			keys = append(keys, i.Key)
+		}
+		if !reflect.DeepEqual(keys, tt.output) && tt.shouldError == false {
+			t.Errorf("%#v: got %v, want %v", tt.input, keys, tt.output)
+		}
+	}
+}
+
+func TestInstallationBaseURL(t *testing.T) {
+	tableCases := []struct {
+		input  []string
+		output string
+	}{
+		{[]string{"https://ipt.sibbr.gov.br/peld/rss.do"}, "https://ipt.sibbr.gov.br/peld"},
+		{[]string{"http://ipt.example.org/eml.do?r=x"}, "http://ipt.example.org"},
+		{[]string{"not a url", "https://ipt.gbif.org/"}, "https://ipt.gbif.org"},
+		{nil, ""},
+	}
+
+	for _, tt := range tableCases {
+		if got := (Installation{Endpoints: tt.input}).BaseURL(); got != tt.output {
+			t.Errorf("%v: got %s, want %s", tt.input, got, tt.output)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	installations := []Installation{
+		{Endpoints: []string{"https://ipt.sibbr.gov.br/peld/rss.do"}},
+		{Endpoints: []string{"https://ipt.sibbr.gov.br/sibbr/rss.do"}},
+		{Endpoints: []string{"https://ipt.inpa.gov.br/rss.do"}},
+		{Endpoints: []string{"https://other.org/ipt/peld/rss.do"}},
+	}
+	previous := map[string]string{
+		"peld":  "http://ipt.sibbr.gov.br/peld/",
+		"gone":  "https://ipt.gone.org/",
+		"local": "http://localhost:8080/ipt",
+	}
+	sources := map[string]string{"peld": IPTSourceRegistry, "gone": IPTSourceRegistry}
+
+	d := Discover(installations, previous, sources, false)
+	want := map[string]string{
+		"peld":   "http://ipt.sibbr.gov.br/peld/",
+		"gone":   "https://ipt.gone.org/",
+		"local":  "http://localhost:8080/ipt",
+		"sibbr":  "https://ipt.sibbr.gov.br/sibbr",
+		"inpa":   "https://ipt.inpa.gov.br",
+		"peld-2": "https://other.org/ipt/peld",
+	}
+	if !reflect.DeepEqual(d.IPTs, want) {
+		t.Errorf("got %v, want %v", d.IPTs, want)
+	}
+	if want := []string{"inpa", "peld-2", "sibbr"}; !reflect.DeepEqual(d.Added, want) {
+		t.Errorf("got added %v, want %v", d.Added, want)
+	}
+	if want := []string{"gone"}; !reflect.DeepEqual(d.Removed, want) {
+		t.Errorf("got removed %v, want %v", d.Removed, want)
+	}
+
+	d = Discover(installations, previous, sources, true)
+	if d.IPTs["gone"] != "" || d.IPTs["local"] == "" {
+		t.Errorf("got %v pruning, want gone removed and local kept", d.IPTs)
+	}
+
+	ini := `; IPTs of Brazil
+[local]
+url=http://localhost:8080/ipt
+timeout=30s
+
+[gone]
+url=https://ipt.gone.org/
+source=registry
+
+[peld]
+url=http://ipt.sibbr.gov.br/peld/
+source=registry
+`
+	buf := &bytes.Buffer{}
+	if err := UpdateIPTs(buf, strings.NewReader(ini), d); err != nil {
+		t.Fatal(err)
+	}
+	wantIni := `; IPTs of Brazil
+[local]
+url=http://localhost:8080/ipt
+timeout=30s
+
+[peld]
+url=http://ipt.sibbr.gov.br/peld/
+source=registry
+
+[inpa]
+url=https://ipt.inpa.gov.br
+source=registry
+
+[peld-2]
+url=https://other.org/ipt/peld
+source=registry
+
+[sibbr]
+url=https://ipt.sibbr.gov.br/sibbr
+source=registry
+`
+	if buf.String() != wantIni {
+		t.Errorf("got \n%s, want \n%s", buf, wantIni)
+	}
+
+	dir, err := ioutil.TempDir("", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ipts.ini")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadIPTSources(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["local"] != "" || got["inpa"] != IPTSourceRegistry || len(got) != 5 {
+		t.Errorf("got sources %v", got)
+	}
+}
@@ -0,0 +1,456 @@
+package iptReport
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Row types of the data files we know how to count, as the local name of the
+// rowType attribute at meta.xml.
+const (
+	RowOccurrence        = "Occurrence"
+	RowEvent             = "Event"
+	RowTaxon             = "Taxon"
+	RowMeasurementOrFact = "MeasurementOrFact"
+)
+
+// Archive is an opened Darwin Core Archive.
+type Archive struct {
+	Core       *ArchiveFile
+	Extensions []*ArchiveFile
+	zip        *zip.ReadCloser
+}
+
+// ArchiveFile describes a data file of an Archive as declared at meta.xml.
+// ID is the column index of the id (or coreid for extensions), -1 if absent.
+type ArchiveFile struct {
+	RowType            string
+	Location           string
+	Encoding           string
+	FieldsTerminatedBy string
+	FieldsEnclosedBy   string
+	IgnoreHeaderLines  int
+	ID                 int
+	Fields             []ArchiveField
+}
+
+// ArchiveField maps a column of an ArchiveFile to a term. Index is -1 for
+// fields which only have a default value.
+type ArchiveField struct {
+	Index   int
+	Term    string
+	Default string
+}
+
+// Record is a row of an ArchiveFile with values keyed by the term local name,
+// e.g. "scientificName".
+type Record struct {
+	ID    string
+	Terms map[string]string
+}
+
+// Get returns the value of term at the record, empty if absent.
+func (r Record) Get(term string) string {
+	return r.Terms[term]
+}
+
+type metaIndex struct {
+	Index *int `xml:"index,attr"`
+}
+
+type metaField struct {
+	Index   *int   `xml:"index,attr"`
+	Term    string `xml:"term,attr"`
+	Default string `xml:"default,attr"`
+}
+
+type metaFile struct {
+	RowType            string      `xml:"rowType,attr"`
+	Encoding           string      `xml:"encoding,attr"`
+	FieldsTerminatedBy string      `xml:"fieldsTerminatedBy,attr"`
+	FieldsEnclosedBy   string      `xml:"fieldsEnclosedBy,attr"`
+	IgnoreHeaderLines  int         `xml:"ignoreHeaderLines,attr"`
+	Location           string      `xml:"files>location"`
+	ID                 *metaIndex  `xml:"id"`
+	CoreID             *metaIndex  `xml:"coreid"`
+	Fields             []metaField `xml:"field"`
+}
+
+type meta struct {
+	Core       *metaFile  `xml:"core"`
+	Extensions []metaFile `xml:"extension"`
+}
+
+// IsRowType reports whether the data file rows are of rowType, compared by
+// local name, e.g. RowOccurrence. Extended types like
+// ExtendedMeasurementOrFact match their base type.
+func (f *ArchiveFile) IsRowType(rowType string) bool {
+	return strings.HasSuffix(TermName(f.RowType), rowType)
+}
+
+// TermName returns the local name of a term IRI, e.g. scientificName for
+// http://rs.tdwg.org/dwc/terms/scientificName.
+func TermName(term string) string {
+	if i := strings.LastIndexAny(term, "/#"); i >= 0 {
+		return term[i+1:]
+	}
+	return term
+}
+
+// unescape turns the escaped delimiters used at meta.xml into real ones.
+func unescape(s string) string {
+	return strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\r`, "\r").Replace(s)
+}
+
+func newArchiveFile(m metaFile) *ArchiveFile {
+	f := &ArchiveFile{
+		RowType:            m.RowType,
+		Location:           m.Location,
+		Encoding:           m.Encoding,
+		FieldsTerminatedBy: unescape(m.FieldsTerminatedBy),
+		FieldsEnclosedBy:   m.FieldsEnclosedBy,
+		IgnoreHeaderLines:  m.IgnoreHeaderLines,
+		ID:                 -1,
+	}
+	if f.FieldsTerminatedBy == "" {
+		f.FieldsTerminatedBy = ","
+	}
+	if m.ID != nil && m.ID.Index != nil {
+		f.ID = *m.ID.Index
+	} else if m.CoreID != nil && m.CoreID.Index != nil {
+		f.ID = *m.CoreID.Index
+	}
+	for _, field := range m.Fields {
+		af := ArchiveField{Index: -1, Term: field.Term, Default: field.Default}
+		if field.Index != nil {
+			af.Index = *field.Index
+		}
+		f.Fields = append(f.Fields, af)
+	}
+	return f
+}
+
+// OpenArchive opens the DwC-A zip at path and reads its meta.xml.
+func OpenArchive(path string) (*Archive, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{zip: z}
+	mf := a.file("meta.xml")
+	if mf == nil {
+		z.Close()
+		return nil, fmt.Errorf("No meta.xml found at %s", path)
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		z.Close()
+		return nil, err
+	}
+	defer rc.Close()
+
+	m := meta{}
+	if err := xml.NewDecoder(rc).Decode(&m); err != nil {
+		z.Close()
+		return nil, err
+	}
+	if m.Core == nil {
+		z.Close()
+		return nil, fmt.Errorf("No core declared at %s", path)
+	}
+
+	a.Core = newArchiveFile(*m.Core)
+	for _, ext := range m.Extensions {
+		a.Extensions = append(a.Extensions, newArchiveFile(ext))
+	}
+
+	return a, nil
+}
+
+// Close closes the underlying zip.
+func (a *Archive) Close() error {
+	return a.zip.Close()
+}
+
+// Files returns the core followed by all extensions.
+func (a *Archive) Files() []*ArchiveFile {
+	return append([]*ArchiveFile{a.Core}, a.Extensions...)
+}
+
+// file finds a zip entry by name, ignoring any leading directory as some
+// publishers zip the archive folder instead of its contents.
+func (a *Archive) file(name string) *zip.File {
+	for _, f := range a.zip.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	for _, f := range a.zip.File {
+		if path.Base(f.Name) == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// cp1252 maps the bytes 0x80 to 0x9F of Windows-1252, which ISO-8859-1
+// leaves to control characters, to their characters. Bytes Windows-1252
+// leaves undefined are kept as control characters.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// latin1Reader converts ISO-8859-1 bytes, or Windows-1252 ones when
+// windows is set, into UTF-8.
+type latin1Reader struct {
+	r       io.Reader
+	windows bool
+	buf     []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(p) < utf8.UTFMax {
+		return 0, io.ErrShortBuffer
+	}
+	if cap(l.buf) < len(p)/3 {
+		l.buf = make([]byte, len(p)/3)
+	}
+	n, err := l.r.Read(l.buf[:len(p)/3])
+	j := 0
+	for _, b := range l.buf[:n] {
+		r := rune(b)
+		if l.windows && b >= 0x80 && b < 0xA0 {
+			r = cp1252[b-0x80]
+		}
+		j += utf8.EncodeRune(p[j:], r)
+	}
+	return j, err
+}
+
+// rows calls fn with the raw columns of every data row at f.
+func (a *Archive) rows(f *ArchiveFile, fn func(row []string) error) error {
+	zf := a.file(f.Location)
+	if zf == nil {
+		return fmt.Errorf("Data file %s not found at archive", f.Location)
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	switch strings.ToLower(f.Encoding) {
+	case "iso-8859-1", "latin1", "latin-1":
+		r = &latin1Reader{r: rc}
+	case "windows-1252", "cp1252":
+		r = &latin1Reader{r: rc, windows: true}
+	}
+
+	skip := f.IgnoreHeaderLines
+
+	if f.FieldsEnclosedBy == `"` && len(f.FieldsTerminatedBy) == 1 {
+		cr := csv.NewReader(r)
+		cr.Comma = rune(f.FieldsTerminatedBy[0])
+		cr.LazyQuotes = true
+		cr.FieldsPerRecord = -1
+		for {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			if skip > 0 {
+				skip--
+			} else if ferr := fn(strings.Split(line, f.FieldsTerminatedBy)); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// Count returns the number of data rows at f.
+func (a *Archive) Count(f *ArchiveFile) (int, error) {
+	count := 0
+	err := a.rows(f, func([]string) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Each calls fn for every record of f, stopping at the first error.
+func (a *Archive) Each(f *ArchiveFile, fn func(Record) error) error {
+	return a.rows(f, func(row []string) error {
+		rec := Record{Terms: make(map[string]string, len(f.Fields))}
+		if f.ID >= 0 && f.ID < len(row) {
+			rec.ID = row[f.ID]
+		}
+		for _, field := range f.Fields {
+			value := ""
+			if field.Index >= 0 && field.Index < len(row) {
+				value = row[field.Index]
+			}
+			if value == "" {
+				value = field.Default
+			}
+			rec.Terms[TermName(field.Term)] = value
+		}
+		return fn(rec)
+	})
+}
+
+// ArchivePath returns where the archive of resource r from the IPT aliased
+// ipt is kept under dir, empty if the resource has no shortname.
+func ArchivePath(dir, ipt string, r Resource) string {
+	shortname := r.Shortname()
+	if shortname == "" {
+		return ""
+	}
+	return filepath.Join(dir, ipt, shortname+".zip")
+}
+
+// DownloadArchive downloads the archive at url into path, creating any
+// missing directory. The file is only replaced once fully downloaded.
+func DownloadArchive(url, path string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Downloading %s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".download")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// WalkArchives calls fn for every archive stored under dir using the layout of
+// ArchivePath, passing the IPT alias and the resource shortname.
+func WalkArchives(dir string, fn func(ipt, shortname, path string) error) error {
+	ipts, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, ipt := range ipts {
+		if !ipt.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(dir, ipt.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.IsDir() || filepath.Ext(file.Name()) != ".zip" {
+				continue
+			}
+			shortname := strings.TrimSuffix(file.Name(), ".zip")
+			if err := fn(ipt.Name(), shortname, filepath.Join(dir, ipt.Name(), file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EachOccurrence calls fn for every occurrence record of the archive, either
+// at the core or at an extension. Occurrences extending an event core lacking
+// coordinates inherit decimalLatitude and decimalLongitude from their event.
+func (a *Archive) EachOccurrence(fn func(Record) error) error {
+	var events map[string][2]string
+	for _, f := range a.Files() {
+		if !f.IsRowType(RowOccurrence) {
+			continue
+		}
+		if f != a.Core && a.Core.IsRowType(RowEvent) && events == nil {
+			events = map[string][2]string{}
+			if err := a.Each(a.Core, func(rec Record) error {
+				events[rec.ID] = [2]string{rec.Get("decimalLatitude"), rec.Get("decimalLongitude")}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := a.Each(f, func(rec Record) error {
+			if event, ok := events[rec.ID]; ok && f != a.Core && rec.Get("decimalLatitude") == "" {
+				rec.Terms["decimalLatitude"] = event[0]
+				rec.Terms["decimalLongitude"] = event[1]
+			}
+			return fn(rec)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Coordinates parses decimalLatitude and decimalLongitude of rec. ok is false
+// when any is missing, not a number or out of range.
+func (r Record) Coordinates() (lat, lon float64, ok bool) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(r.Get("decimalLatitude")), 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(r.Get("decimalLongitude")), 64)
+	if err != nil || math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
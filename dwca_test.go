@@ -0,0 +1,264 @@
+package iptReport
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMeta = `<archive xmlns="http://rs.tdwg.org/dwc/text/" metadata="eml.xml">
+  <core encoding="UTF-8" fieldsTerminatedBy="\t" linesTerminatedBy="\n" fieldsEnclosedBy="" ignoreHeaderLines="1" rowType="http://rs.tdwg.org/dwc/terms/Event">
+    <files>
+      <location>event.txt</location>
+    </files>
+    <id index="0" />
+    <field index="1" term="http://rs.tdwg.org/dwc/terms/eventDate"/>
+    <field term="http://rs.tdwg.org/dwc/terms/country" default="Brazil"/>
+  </core>
+  <extension encoding="UTF-8" fieldsTerminatedBy="," linesTerminatedBy="\n" fieldsEnclosedBy="&quot;" ignoreHeaderLines="1" rowType="http://rs.tdwg.org/dwc/terms/Occurrence">
+    <files>
+      <location>occurrence.txt</location>
+    </files>
+    <coreid index="0" />
+    <field index="1" term="http://rs.tdwg.org/dwc/terms/occurrenceID"/>
+    <field index="2" term="http://rs.tdwg.org/dwc/terms/scientificName"/>
+  </extension>
+  <extension encoding="UTF-8" fieldsTerminatedBy="\t" linesTerminatedBy="\n" fieldsEnclosedBy="" ignoreHeaderLines="1" rowType="http://rs.iobis.org/obis/terms/ExtendedMeasurementOrFact">
+    <files>
+      <location>extendedmeasurementorfact.txt</location>
+    </files>
+    <coreid index="0" />
+    <field index="1" term="http://rs.tdwg.org/dwc/terms/measurementType"/>
+  </extension>
+</archive>`
+
+// testArchive holds the files of a small event archive with occurrence and
+// measurement extensions.
+var testArchive = map[string]string{
+	"meta.xml":  testMeta,
+	"event.txt": "id\teventDate\ne1\t2017-01-01\ne2\t2017-02-01\n",
+	"occurrence.txt": "id,occurrenceID,scientificName\n" +
+		"e1,o1,\"Puma concolor (Linnaeus, 1771)\"\n" +
+		"e1,o2,Tapirus terrestris\n" +
+		"e2,o3,Tapirus terrestris\n",
+	"extendedmeasurementorfact.txt": "id\tmeasurementType\ne1\tdepth\n",
+}
+
+// writeArchive zips files into a DwC-A under dir and returns its path.
+func writeArchive(t *testing.T, dir, name string, files map[string]string) string {
+	path := filepath.Join(dir, name)
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	z := zip.NewWriter(out)
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dwca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tableCases := []struct {
+		input       map[string]string
+		shouldError bool
+	}{
+		{testArchive, false},
+		{map[string]string{"event.txt": "id\n"}, true},
+		{map[string]string{"meta.xml": "<archive></archive>"}, true},
+		{map[string]string{"meta.xml": "<archive"}, true},
+	}
+
+	for i, tt := range tableCases {
+		path := writeArchive(t, dir, fmt.Sprintf("open%d.zip", i), tt.input)
+		a, err := OpenArchive(path)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error opening %v", tt.input)
+		}
+		if err == nil {
+			a.Close()
+		}
+	}
+
+	path := writeArchive(t, dir, "valid.zip", testArchive)
+	a, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if a.Core.Location != "event.txt" || a.Core.FieldsTerminatedBy != "\t" || a.Core.ID != 0 {
+		t.Errorf("got core \n%#v", a.Core)
+	}
+	if len(a.Extensions) != 2 || !a.Extensions[1].IsRowType(RowMeasurementOrFact) {
+		t.Errorf("got extensions \n%#v", a.Extensions)
+	}
+}
+
+func TestArchiveEach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dwca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := OpenArchive(writeArchive(t, dir, "each.zip", testArchive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	tableCases := []struct {
+		input  *ArchiveFile
+		output []Record
+	}{
+		{
+			a.Core,
+			[]Record{
+				{"e1", map[string]string{"eventDate": "2017-01-01", "country": "Brazil"}},
+				{"e2", map[string]string{"eventDate": "2017-02-01", "country": "Brazil"}},
+			},
+		},
+		{
+			a.Extensions[0],
+			[]Record{
+				{"e1", map[string]string{"occurrenceID": "o1", "scientificName": "Puma concolor (Linnaeus, 1771)"}},
+				{"e1", map[string]string{"occurrenceID": "o2", "scientificName": "Tapirus terrestris"}},
+				{"e2", map[string]string{"occurrenceID": "o3", "scientificName": "Tapirus terrestris"}},
+			},
+		},
+	}
+
+	for _, tt := range tableCases {
+		records := []Record{}
+		if err := a.Each(tt.input, func(r Record) error {
+			records = append(records, r)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(records, tt.output) {
+			t.Errorf("got \n%#v, want \n%#v", records, tt.output)
+		}
+	}
+}
+
+func TestRecordCoordinates(t *testing.T) {
+	tableCases := []struct {
+		lat, lon string
+		ok       bool
+	}{
+		{"-15.8", "-47.9", true},
+		{" -90 ", "180", true},
+		{"", "-47.9", false},
+		{"-91", "-47.9", false},
+		{"-15.8", "181", false},
+		{"NaN", "-47.9", false},
+		{"-15.8", "nan", false},
+		{"Inf", "-47.9", false},
+		{"-15.8", "-Inf", false},
+	}
+
+	for _, tt := range tableCases {
+		rec := Record{Terms: map[string]string{"decimalLatitude": tt.lat, "decimalLongitude": tt.lon}}
+		if _, _, ok := rec.Coordinates(); ok != tt.ok {
+			t.Errorf("%q,%q: got ok %v, want %v", tt.lat, tt.lon, ok, tt.ok)
+		}
+	}
+}
+
+func TestLatin1Reader(t *testing.T) {
+	tableCases := []struct {
+		input   string
+		windows bool
+		output  string
+	}{
+		{"Jos\xe9 S\xe3o Paulo", false, "José São Paulo"},
+		{"Jos\xe9 S\xe3o Paulo", true, "José São Paulo"},
+		{"\x93Mata Atl\xe2ntica\x94 \x80 5 \x96 6", true, "“Mata Atlântica” € 5 – 6"},
+		{"\x93a\x94", false, "\u0093a\u0094"},
+	}
+
+	for _, tt := range tableCases {
+		out, err := ioutil.ReadAll(&latin1Reader{r: strings.NewReader(tt.input), windows: tt.windows})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != tt.output {
+			t.Errorf("%q: got %q, want %q", tt.input, out, tt.output)
+		}
+	}
+}
+
+func TestDownloadArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dwca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("r") != "ok" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("zip"))
+	}))
+	defer ts.Close()
+
+	tableCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{ts.URL + "/archive.do?r=ok", false},
+		{ts.URL + "/archive.do?r=missing", true},
+		{"THIS URL SHOULDN'T EXIST", true},
+	}
+
+	for _, tt := range tableCases {
+		path := filepath.Join(dir, "ipt", "ok.zip")
+		err := DownloadArchive(tt.input, path)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error downloading %s", tt.input)
+		}
+	}
+
+	found := []string{}
+	if err := WalkArchives(dir, func(ipt, shortname, path string) error {
+		found = append(found, ipt+"/"+shortname)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(found, []string{"ipt/ok"}) {
+		t.Errorf("got %v", found)
+	}
+}
@@ -0,0 +1,279 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Trend models.
+const (
+	TrendLinear      = "linear"
+	TrendExponential = "exponential"
+)
+
+// Z95 is the normal quantile of 95% confidence bands.
+const Z95 = 1.96
+
+// forecastHorizon is how far ahead of the last observation target dates are
+// searched for.
+const forecastHorizon = 100 * 365
+
+// Trend is a least squares fit of observations, taken from Origin to Last,
+// against the days since Origin: a line, or a line of their logarithms for
+// exponential trends. SE is the standard error of the residuals, zero with
+// less than three observations, and MeanX and SXX the mean and sum of
+// squared deviations of the days, used by confidence bands.
+type Trend struct {
+	Model     string
+	Origin    time.Time
+	Last      time.Time
+	N         int
+	Intercept float64
+	Slope     float64
+	SE        float64
+	MeanX     float64
+	SXX       float64
+}
+
+// ErrTooFewPoints is returned fitting trends to less than two observations
+// at different times.
+var ErrTooFewPoints = errors.New("Not enough observations to fit a trend")
+
+// FitTrend fits model to values observed at times, oldest first. Exponential
+// trends need positive values.
+func FitTrend(model string, times []time.Time, values []float64) (*Trend, error) {
+	if model != TrendLinear && model != TrendExponential {
+		return nil, fmt.Errorf("Unknown trend %s", model)
+	}
+	if len(times) < 2 || !times[len(times)-1].After(times[0]) {
+		return nil, ErrTooFewPoints
+	}
+
+	t := &Trend{Model: model, Origin: times[0], Last: times[len(times)-1], N: len(values)}
+	xs, ys := make([]float64, len(values)), make([]float64, len(values))
+	for i, v := range values {
+		xs[i] = t.days(times[i])
+		ys[i] = v
+		if model == TrendExponential {
+			if v <= 0 {
+				return nil, fmt.Errorf("Exponential trend needs positive values, got %v", v)
+			}
+			ys[i] = math.Log(v)
+		}
+	}
+
+	n := float64(t.N)
+	var sy float64
+	for i := range xs {
+		t.MeanX += xs[i] / n
+		sy += ys[i]
+	}
+	var sxy float64
+	for i := range xs {
+		d := xs[i] - t.MeanX
+		t.SXX += d * d
+		sxy += d * ys[i]
+	}
+	t.Slope = sxy / t.SXX
+	t.Intercept = sy/n - t.Slope*t.MeanX
+
+	if t.N > 2 {
+		var sse float64
+		for i := range xs {
+			r := ys[i] - t.Intercept - t.Slope*xs[i]
+			sse += r * r
+		}
+		t.SE = math.Sqrt(sse / (n - 2))
+	}
+
+	return t, nil
+}
+
+// days returns the days from the origin to at.
+func (t *Trend) days(at time.Time) float64 {
+	return at.Sub(t.Origin).Hours() / 24
+}
+
+// time returns the time x days after the origin.
+func (t *Trend) time(x float64) time.Time {
+	return t.Origin.Add(time.Duration(x * 24 * float64(time.Hour)))
+}
+
+// value turns a fitted value at x, shifted by z standard errors of
+// prediction, into the observations scale.
+func (t *Trend) value(x, z float64) float64 {
+	y := t.Intercept + t.Slope*x
+	if z != 0 {
+		y += z * t.SE * math.Sqrt(1+1/float64(t.N)+(x-t.MeanX)*(x-t.MeanX)/t.SXX)
+	}
+	if t.Model == TrendExponential {
+		return math.Exp(y)
+	}
+	return y
+}
+
+// At returns the trend value at at.
+func (t *Trend) At(at time.Time) float64 {
+	return t.value(t.days(at), 0)
+}
+
+// Band returns the prediction band at at, z standard errors wide on each
+// side, e.g. Z95.
+func (t *Trend) Band(at time.Time, z float64) (low, high float64) {
+	x := t.days(at)
+	return t.value(x, -z), t.value(x, z)
+}
+
+// When returns when the trend reaches target, false when it never does
+// within a century. A target reached by the last observation is reached then.
+func (t *Trend) When(target float64) (time.Time, bool) {
+	return t.reach(target, 0)
+}
+
+// WhenBand returns the earliest and latest dates target is expected to be
+// reached: when the upper and the lower band, z standard errors wide, reach
+// it. The latest is zero when the lower band doesn't reach target within a
+// century.
+func (t *Trend) WhenBand(target, z float64) (earliest, latest time.Time) {
+	earliest, _ = t.reach(target, z)
+	latest, _ = t.reach(target, -z)
+	return earliest, latest
+}
+
+// reach returns when the trend shifted by z standard errors first reaches
+// target after the last observation, searching day by day up to the
+// horizon.
+func (t *Trend) reach(target, z float64) (time.Time, bool) {
+	last := t.days(t.Last)
+	if t.value(last, z) >= target {
+		return t.Last, true
+	}
+	if z == 0 {
+		if t.Slope <= 0 {
+			return time.Time{}, false
+		}
+		y := target
+		if t.Model == TrendExponential {
+			y = math.Log(target)
+		}
+		x := (y - t.Intercept) / t.Slope
+		if x > last+forecastHorizon {
+			return time.Time{}, false
+		}
+		return t.time(x), true
+	}
+	for x := last + 1; x <= last+forecastHorizon; x++ {
+		if t.value(x, z) >= target {
+			return t.time(x), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// metricValue returns the metric of a point, see the Metric constants.
+func metricValue(p SeriesPoint, metric string) (float64, error) {
+	switch metric {
+	case MetricResources:
+		return float64(p.Resources), nil
+	case MetricOccurrences:
+		return float64(p.Occurrences), nil
+	case MetricEvents:
+		return float64(p.Events), nil
+	case MetricMeasurements:
+		return float64(p.Measurements), nil
+	}
+	return 0, fmt.Errorf("Unknown metric %s", metric)
+}
+
+// SeriesForecast is the Trend of Metric of a Series of a time series, last
+// observed as Current, or the error fitting it.
+type SeriesForecast struct {
+	Series  string
+	Metric  string
+	Current float64
+	Trend   *Trend
+	Err     error
+}
+
+// ForecastSeries fits model to metric of every series of ts, in series order.
+func ForecastSeries(ts *TimeSeries, metric, model string) ([]SeriesForecast, error) {
+	if _, err := metricValue(SeriesPoint{}, metric); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	times := map[string][]time.Time{}
+	values := map[string][]float64{}
+	for _, p := range ts.Points {
+		if _, ok := times[p.Series]; !ok {
+			names = append(names, p.Series)
+		}
+		v, _ := metricValue(p, metric)
+		times[p.Series] = append(times[p.Series], p.Period)
+		values[p.Series] = append(values[p.Series], v)
+	}
+	sort.Strings(names)
+
+	forecasts := []SeriesForecast{}
+	for _, name := range names {
+		vs := values[name]
+		f := SeriesForecast{Series: name, Metric: metric, Current: vs[len(vs)-1]}
+		f.Trend, f.Err = FitTrend(model, times[name], vs)
+		forecasts = append(forecasts, f)
+	}
+	return forecasts, nil
+}
+
+// formatForecast formats a forecast value without decimals.
+func formatForecast(v float64) string {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
+
+// WriteForecastCSV writes a line per forecast with its value and band, z
+// standard errors wide, at at and, when target is positive, when target is
+// expected to be reached, earliest and latest.
+func WriteForecastCSV(w io.Writer, forecasts []SeriesForecast, at time.Time, z, target float64) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"Series", "Metric", "Model", "Current", "LastObserved", "Date", "Forecast", "Low", "High",
+		"Target", "Reached", "Earliest", "Latest", "Error"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for _, f := range forecasts {
+		var model, last, forecast, low, high, goal, reached, earliest, latest, failure string
+		if f.Err != nil {
+			failure = f.Err.Error()
+		} else {
+			t := f.Trend
+			l, h := t.Band(at, z)
+			model, last = t.Model, formatDate(t.Last)
+			forecast, low, high = formatForecast(t.At(at)), formatForecast(l), formatForecast(h)
+			if target > 0 {
+				goal = formatForecast(target)
+				if when, ok := t.When(target); ok {
+					reached = formatDate(when)
+				}
+				e, l := t.WhenBand(target, z)
+				earliest, latest = formatDate(e), formatDate(l)
+			}
+		}
+		line := []string{f.Series, f.Metric, model, formatForecast(f.Current), last, formatDate(at),
+			forecast, low, high, goal, reached, earliest, latest, failure}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
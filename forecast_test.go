@@ -0,0 +1,121 @@
+package iptReport
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFitTrend(t *testing.T) {
+	origin := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return origin.AddDate(0, 0, d) }
+	times := []time.Time{day(0), day(10), day(20), day(30)}
+
+	tableCases := []struct {
+		model       string
+		values      []float64
+		at          time.Time
+		output      float64
+		target      float64
+		when        time.Time
+		shouldError bool
+	}{
+		{TrendLinear, []float64{10, 30, 50, 70}, day(40), 90, 110, day(50), false},
+		{TrendExponential, []float64{100, 200, 400, 800}, day(40), 1600, 3200, day(50), false},
+		{TrendLinear, []float64{10, 30, 50, 70}, day(40), 0, 50, day(30), false},
+		{TrendExponential, []float64{0, 1, 2, 3}, day(40), 0, 0, time.Time{}, true},
+		{"cubic", []float64{10, 30, 50, 70}, day(40), 0, 0, time.Time{}, true},
+	}
+
+	for _, tt := range tableCases {
+		trend, err := FitTrend(tt.model, times, tt.values)
+		if (err != nil) != tt.shouldError {
+			t.Errorf("%s %v: got error %v", tt.model, tt.values, err)
+			continue
+		}
+		if tt.shouldError {
+			continue
+		}
+		if tt.output != 0 && math.Abs(trend.At(tt.at)-tt.output) > 1e-6 {
+			t.Errorf("%s: got %v at %s, want %v", tt.model, trend.At(tt.at), tt.at, tt.output)
+		}
+		if when, ok := trend.When(tt.target); !ok || when.Sub(tt.when) > time.Minute || tt.when.Sub(when) > time.Minute {
+			t.Errorf("%s: got %s reaching %v, want %s", tt.model, when, tt.target, tt.when)
+		}
+	}
+
+	if _, err := FitTrend(TrendLinear, times[:1], []float64{1}); err != ErrTooFewPoints {
+		t.Errorf("got error %v, want ErrTooFewPoints", err)
+	}
+	declining, _ := FitTrend(TrendLinear, times, []float64{70, 50, 30, 10})
+	if _, ok := declining.When(100); ok {
+		t.Error("declining trend shouldn't reach a higher target")
+	}
+	month := func(m int) time.Time { return origin.AddDate(0, m, 0) }
+	slow, _ := FitTrend(TrendLinear, []time.Time{month(0), month(1), month(2)}, []float64{1000, 1001, 1002})
+	if when, ok := slow.When(5000000); ok {
+		t.Errorf("slow trend got %s reaching 5000000, want never", when)
+	}
+}
+
+func TestTrendBand(t *testing.T) {
+	origin := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{}
+	for d := 0; d < 50; d += 10 {
+		times = append(times, origin.AddDate(0, 0, d))
+	}
+	trend, err := FitTrend(TrendLinear, times, []float64{10, 35, 45, 75, 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	near, far := origin.AddDate(0, 0, 50), origin.AddDate(0, 0, 200)
+	low, high := trend.Band(near, Z95)
+	if v := trend.At(near); !(low < v && v < high) {
+		t.Errorf("got %v outside band %v-%v", v, low, high)
+	}
+	farLow, farHigh := trend.Band(far, Z95)
+	if farHigh-farLow <= high-low {
+		t.Errorf("band should widen away from the observations: %v at %s, %v at %s", high-low, near, farHigh-farLow, far)
+	}
+
+	when, _ := trend.When(200)
+	earliest, latest := trend.WhenBand(200, Z95)
+	if !earliest.Before(when) || !when.Before(latest) {
+		t.Errorf("got %s reaching 200, outside %s-%s", when, earliest, latest)
+	}
+}
+
+func TestForecastSeries(t *testing.T) {
+	month := func(m int) time.Time { return time.Date(2018, time.Month(m), 1, 0, 0, 0, 0, time.UTC) }
+	ts := &TimeSeries{Level: LevelIPT, Interval: IntervalMonthly, Points: []SeriesPoint{
+		{Period: month(1), Series: "b", Occurrences: 100, Resources: 1},
+		{Period: month(1), Series: "a", Occurrences: 10, Resources: 1},
+		{Period: month(2), Series: "b", Occurrences: 200, Resources: 2},
+		{Period: month(3), Series: "b", Occurrences: 300, Resources: 3},
+	}}
+
+	if _, err := ForecastSeries(ts, "species", TrendLinear); err == nil {
+		t.Error("expected error for an unknown metric")
+	}
+	forecasts, err := ForecastSeries(ts, MetricOccurrences, TrendLinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecasts) != 2 || forecasts[0].Series != "a" || forecasts[0].Err != ErrTooFewPoints ||
+		forecasts[1].Current != 300 || forecasts[1].Trend == nil {
+		t.Fatalf("got %#v", forecasts)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteForecastCSV(buf, forecasts, month(6), Z95, 1000); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], ErrTooFewPoints.Error()) ||
+		!strings.HasPrefix(lines[2], "b,occurrences,linear,300,2018-03-01,2018-06-01,") {
+		t.Errorf("got \n%s", buf)
+	}
+}
@@ -0,0 +1,390 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zieckey/goini"
+)
+
+// Metrics goals are measured by.
+const (
+	MetricResources     = "resources"
+	MetricOccurrences   = "occurrences"
+	MetricEvents        = "events"
+	MetricMeasurements  = "measurements"
+	MetricOrganizations = "organizations"
+	MetricIPTs          = "ipts"
+)
+
+// Statuses of goals.
+const (
+	GoalMet       = "met"
+	GoalOnTrack   = "on track"
+	GoalAtRisk    = "at risk"
+	GoalMissed    = "missed"
+	GoalNoHistory = "not enough history"
+)
+
+// Goal is a target of reaching Threshold of Metric by Deadline over the
+// resources of IPTs and Organizations, by alias and name, or of every IPT
+// when both are empty, e.g. nationally. A region, or a country, is the list
+// of its IPTs and organizations. Each goals apply to each organization, or
+// else each IPT, listed, their attainment being the share of them reaching
+// Threshold. Trend is the model projecting the goal, see FitTrend.
+type Goal struct {
+	Name          string
+	IPTs          []string
+	Organizations []string
+	Each          bool
+	Metric        string
+	Threshold     float64
+	Deadline      time.Time
+	Trend         string
+}
+
+// ReadGoals reads an ini file where each section is a goal, e.g.:
+//
+//	[5 million occurrences]
+//	metric=occurrences
+//	threshold=5000000
+//	deadline=2027-12-31
+//	trend=exponential
+//
+//	[state universities publish]
+//	organizations=UFRJ; USP; UNICAMP
+//	each=true
+//	metric=resources
+//	threshold=1
+//	deadline=2025-12-31
+//
+// Goals of a region, or country, list its IPTs and organizations, and goals
+// listing neither cover every IPT. Metrics are resources, occurrences,
+// events, measurements, organizations publishing and IPTs publishing. Trends
+// are linear, the default, or exponential.
+func ReadGoals(path string) ([]Goal, error) {
+	ini := goini.New()
+	if err := ini.ParseFile(path); err != nil {
+		return nil, err
+	}
+
+	goals := []Goal{}
+	for name, kv := range ini.GetAll() {
+		if name == goini.DefaultSection {
+			continue
+		}
+		if _, ok := kv["scope"]; ok {
+			return nil, fmt.Errorf("Goal %s: scope isn't supported, list the ipts or organizations covered", name)
+		}
+		g := Goal{Name: name, IPTs: splitList(kv["ipts"]), Organizations: splitList(kv["organizations"]),
+			Metric: kv["metric"], Trend: kv["trend"]}
+		if g.Trend == "" {
+			g.Trend = TrendLinear
+		}
+		if each, ok := kv["each"]; ok {
+			b, err := strconv.ParseBool(each)
+			if err != nil {
+				return nil, fmt.Errorf("Goal %s: invalid each %q", name, each)
+			}
+			g.Each = b
+		}
+		threshold, err := strconv.ParseFloat(kv["threshold"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Goal %s: invalid threshold %q", name, kv["threshold"])
+		}
+		g.Threshold = threshold
+		if g.Deadline, err = time.Parse("2006-01-02", kv["deadline"]); err != nil {
+			return nil, fmt.Errorf("Goal %s: invalid deadline %q", name, kv["deadline"])
+		}
+		if err := g.validate(); err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+	sort.Slice(goals, func(i, j int) bool { return goals[i].Name < goals[j].Name })
+
+	return goals, nil
+}
+
+func (g Goal) validate() error {
+	switch g.Metric {
+	case MetricResources, MetricOccurrences, MetricEvents, MetricMeasurements, MetricOrganizations, MetricIPTs:
+	default:
+		return fmt.Errorf("Goal %s: unknown metric %q", g.Name, g.Metric)
+	}
+	if g.Trend != TrendLinear && g.Trend != TrendExponential {
+		return fmt.Errorf("Goal %s: unknown trend %q", g.Name, g.Trend)
+	}
+	if g.Each && len(g.IPTs) == 0 && len(g.Organizations) == 0 {
+		return fmt.Errorf("Goal %s: each needs ipts or organizations", g.Name)
+	}
+	return nil
+}
+
+// members returns a goal per organization, or else IPT, of an Each goal.
+func (g Goal) members() []Goal {
+	members := []Goal{}
+	if len(g.Organizations) > 0 {
+		for _, org := range g.Organizations {
+			m := g
+			m.IPTs, m.Organizations, m.Each = nil, []string{org}, false
+			members = append(members, m)
+		}
+		return members
+	}
+	for _, ipt := range g.IPTs {
+		m := g
+		m.IPTs, m.Organizations, m.Each = []string{ipt}, nil, false
+		members = append(members, m)
+	}
+	return members
+}
+
+// Coverage describes the IPTs and organizations the goal is measured over.
+func (g Goal) Coverage() string {
+	parts := []string{}
+	if len(g.IPTs) > 0 {
+		parts = append(parts, "IPTs "+strings.Join(g.IPTs, ", "))
+	}
+	if len(g.Organizations) > 0 {
+		parts = append(parts, "organizations "+strings.Join(g.Organizations, ", "))
+	}
+	if len(parts) == 0 {
+		return "every IPT"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Target returns the value attaining the goal, the number of members of Each
+// goals.
+func (g Goal) Target() float64 {
+	if g.Each {
+		return float64(len(g.members()))
+	}
+	return g.Threshold
+}
+
+// Measure returns the value of the goal over the resources of each IPT,
+// comparing organizations by their key in orgs.
+func (g Goal) Measure(ipts map[string][]Resource, orgs *OrgNormalizer) float64 {
+	if g.Each {
+		met := 0.0
+		for _, m := range g.members() {
+			if m.Measure(ipts, orgs) >= g.Threshold {
+				met++
+			}
+		}
+		return met
+	}
+
+	inIPTs := map[string]bool{}
+	for _, ipt := range g.IPTs {
+		inIPTs[ipt] = true
+	}
+	inOrgs := map[string]bool{}
+	for _, org := range g.Organizations {
+		inOrgs[orgs.Key(org)] = true
+	}
+	all := len(inIPTs) == 0 && len(inOrgs) == 0
+
+	value := 0
+	publishers := map[string]bool{}
+	for ipt, resources := range ipts {
+		for _, r := range resources {
+			if !all && !inIPTs[ipt] && !inOrgs[orgs.Key(r.Organization)] {
+				continue
+			}
+			switch g.Metric {
+			case MetricResources:
+				value++
+			case MetricOccurrences:
+				value += r.Occurrences
+			case MetricEvents:
+				value += r.Events
+			case MetricMeasurements:
+				value += r.Measurements
+			case MetricOrganizations:
+				publishers[orgs.Key(r.Organization)] = true
+			case MetricIPTs:
+				publishers[ipt] = true
+			}
+		}
+	}
+	if g.Metric == MetricOrganizations || g.Metric == MetricIPTs {
+		delete(publishers, "")
+		value = len(publishers)
+	}
+	return float64(value)
+}
+
+// GoalStatus is the attainment of a Goal: its Current value and the one
+// Projected at its deadline, with their fractions of the target. Low and High
+// bound the 95% prediction band of the projection and Expected is when the
+// trend reaches the target, zero if it never does.
+type GoalStatus struct {
+	Goal                Goal
+	Current             float64
+	Attainment          float64
+	Projected           float64
+	ProjectedAttainment float64
+	Low                 float64
+	High                float64
+	Expected            time.Time
+	Status              string
+}
+
+// GoalsReport holds the status of goals at Now.
+type GoalsReport struct {
+	Now   time.Time
+	Goals []GoalStatus
+}
+
+// EvaluateGoals measures goals at each of snapshots, oldest first, the last
+// being the current crawl, and projects them to their deadlines from the
+// trend of the whole history. Exponential trends fall back to linear ones
+// when they can't be fitted, e.g. to zero values, or overflow by the
+// deadline. IPTs failing at a snapshot count the resources of their last
+// successful crawl.
+func EvaluateGoals(goals []Goal, snapshots []*Snapshot, now time.Time, orgs *OrgNormalizer) GoalsReport {
+	report := GoalsReport{Now: now}
+	states := carryForward(snapshots)
+
+	for _, g := range goals {
+		status := GoalStatus{Goal: g}
+		times, values := []time.Time{}, []float64{}
+		for i, ipts := range states {
+			times = append(times, snapshots[i].Taken)
+			values = append(values, g.Measure(ipts, orgs))
+		}
+		if len(values) > 0 {
+			status.Current = values[len(values)-1]
+		}
+
+		target := g.Target()
+		switch {
+		case status.Current >= target:
+			status.Status = GoalMet
+		case !now.Before(g.Deadline):
+			status.Status = GoalMissed
+		case len(values) < 2 || !times[len(times)-1].After(times[0]):
+			status.Status = GoalNoHistory
+		default:
+			trend, err := FitTrend(g.Trend, times, values)
+			if err == nil {
+				status.Projected = trend.At(g.Deadline)
+				status.Low, status.High = trend.Band(g.Deadline, Z95)
+			}
+			if err != nil || !finite(status.Projected) || !finite(status.Low) || !finite(status.High) {
+				trend, _ = FitTrend(TrendLinear, times, values)
+				status.Projected = trend.At(g.Deadline)
+				status.Low, status.High = trend.Band(g.Deadline, Z95)
+			}
+			status.Expected, _ = trend.When(target)
+			clamp := func(v *float64) {
+				if *v < 0 {
+					*v = 0
+				}
+				if g.Each && *v > target {
+					*v = target
+				}
+			}
+			clamp(&status.Projected)
+			clamp(&status.Low)
+			clamp(&status.High)
+			if status.Projected >= target {
+				status.Status = GoalOnTrack
+			} else {
+				status.Status = GoalAtRisk
+			}
+		}
+		if status.Status != GoalAtRisk && status.Status != GoalOnTrack {
+			status.Projected, status.Low, status.High = status.Current, status.Current, status.Current
+		}
+		if target > 0 {
+			status.Attainment = status.Current / target
+			status.ProjectedAttainment = status.Projected / target
+		}
+
+		report.Goals = append(report.Goals, status)
+	}
+
+	return report
+}
+
+// AtRisk returns the goals at risk or missed.
+func (r GoalsReport) AtRisk() []GoalStatus {
+	risky := []GoalStatus{}
+	for _, s := range r.Goals {
+		if s.Status == GoalAtRisk || s.Status == GoalMissed {
+			risky = append(risky, s)
+		}
+	}
+	return risky
+}
+
+// finite reports whether v is neither infinite nor NaN.
+func finite(v float64) bool {
+	return !math.IsInf(v, 0) && !math.IsNaN(v)
+}
+
+// formatValue formats a goal value without decimals.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
+
+// WriteText writes a paragraph per goal.
+func (r GoalsReport) WriteText(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "Goals at %s\n", formatDate(r.Now))
+	for _, s := range r.Goals {
+		g := s.Goal
+		unit := g.Metric
+		if g.Each {
+			unit = fmt.Sprintf("members with %s %s", formatValue(g.Threshold), g.Metric)
+		}
+		fmt.Fprintf(buf, "\n%s (%s)\n", g.Name, g.Coverage())
+		fmt.Fprintf(buf, "  %s of %s %s by %s, %.0f%%\n", formatValue(s.Current), formatValue(g.Target()),
+			unit, formatDate(g.Deadline), s.Attainment*100)
+		if s.Status == GoalOnTrack || s.Status == GoalAtRisk {
+			fmt.Fprintf(buf, "  projected %s, %s to %s, %.0f%%: %s\n", formatValue(s.Projected),
+				formatValue(s.Low), formatValue(s.High), s.ProjectedAttainment*100, s.Status)
+			if !s.Expected.IsZero() {
+				fmt.Fprintf(buf, "  expected by %s\n", formatDate(s.Expected))
+			}
+		} else {
+			fmt.Fprintf(buf, "  %s\n", strings.ToUpper(s.Status[:1])+s.Status[1:])
+		}
+	}
+	return buf.Flush()
+}
+
+// WriteCSV writes a line per goal.
+func (r GoalsReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"Goal", "Coverage", "Metric", "Each", "Target", "Deadline", "Current", "Attainment",
+		"Projected", "ProjectedAttainment", "Low", "High", "Expected", "Status"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for _, s := range r.Goals {
+		g := s.Goal
+		line := []string{g.Name, g.Coverage(), g.Metric, strconv.FormatBool(g.Each), formatValue(g.Target()),
+			formatDate(g.Deadline), formatValue(s.Current), strconv.FormatFloat(s.Attainment, 'f', 3, 64),
+			formatValue(s.Projected), strconv.FormatFloat(s.ProjectedAttainment, 'f', 3, 64),
+			formatValue(s.Low), formatValue(s.High), formatDate(s.Expected), s.Status}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,145 @@
+package iptReport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadGoals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deadline := time.Date(2027, 12, 31, 0, 0, 0, 0, time.UTC)
+	tableCases := []struct {
+		config      string
+		output      []Goal
+		shouldError bool
+	}{
+		{"[occ]\nmetric=occurrences\nthreshold=5000000\ndeadline=2027-12-31\ntrend=exponential\n" +
+			"[unis]\norganizations=UFRJ; USP\neach=true\nmetric=resources\nthreshold=1\ndeadline=2027-12-31\n",
+			[]Goal{
+				{Name: "occ", IPTs: []string{}, Organizations: []string{}, Metric: MetricOccurrences,
+					Threshold: 5000000, Deadline: deadline, Trend: TrendExponential},
+				{Name: "unis", IPTs: []string{}, Organizations: []string{"UFRJ", "USP"}, Each: true,
+					Metric: MetricResources, Threshold: 1, Deadline: deadline, Trend: TrendLinear},
+			}, false},
+		{"[x]\nmetric=species\nthreshold=1\ndeadline=2027-12-31\n", nil, true},
+		{"[x]\nmetric=resources\nthreshold=many\ndeadline=2027-12-31\n", nil, true},
+		{"[x]\nmetric=resources\nthreshold=1\ndeadline=2027\n", nil, true},
+		{"[x]\nmetric=resources\nthreshold=1\ndeadline=2027-12-31\ntrend=cubic\n", nil, true},
+		{"[x]\nscope=region\nmetric=resources\nthreshold=1\ndeadline=2027-12-31\n", nil, true},
+		{"[x]\neach=true\nmetric=resources\nthreshold=1\ndeadline=2027-12-31\n", nil, true},
+	}
+
+	for i, tt := range tableCases {
+		path := filepath.Join(dir, "goals.ini")
+		if err := ioutil.WriteFile(path, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadGoals(path)
+		if (err != nil) != tt.shouldError {
+			t.Errorf("case %d: got error %v", i, err)
+			continue
+		}
+		if !tt.shouldError && !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("case %d: got \n%#v, want \n%#v", i, got, tt.output)
+		}
+	}
+}
+
+func TestEvaluateGoals(t *testing.T) {
+	day := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	snapshots := []*Snapshot{
+		{Taken: day(2018, 1, 1), IPTs: []IPTSnapshot{
+			{Name: "a", Resources: []Resource{{Name: "x", Organization: "UFRJ", Occurrences: 100}}},
+		}},
+		{Taken: day(2018, 7, 1), IPTs: []IPTSnapshot{
+			{Name: "a", Resources: []Resource{{Name: "x", Organization: "UFRJ", Occurrences: 200}}},
+			{Name: "b", Resources: []Resource{{Name: "y", Organization: "usp"}}},
+		}},
+		{Taken: day(2019, 1, 1), IPTs: []IPTSnapshot{
+			{Name: "a", Err: "timeout"},
+			{Name: "b", Resources: []Resource{{Name: "y", Organization: "USP", Occurrences: 100}}},
+		}},
+	}
+	now := day(2019, 1, 1)
+	goal := func(name, metric string, threshold float64, deadline time.Time) Goal {
+		return Goal{Name: name, Metric: metric, Threshold: threshold, Deadline: deadline, Trend: TrendLinear}
+	}
+	unis := Goal{Name: "unis", Organizations: []string{"UFRJ", "USP", "UNICAMP"}, Each: true,
+		Metric: MetricResources, Threshold: 1, Deadline: day(2020, 1, 1), Trend: TrendLinear}
+	inpa := Goal{Name: "inpa", IPTs: []string{"b"}, Metric: MetricOccurrences, Threshold: 1000,
+		Deadline: day(2020, 1, 1), Trend: TrendExponential}
+
+	tableCases := []struct {
+		goal               Goal
+		current, projected float64
+		status             string
+	}{
+		{goal("met", MetricOccurrences, 300, day(2020, 1, 1)), 300, 300, GoalMet},
+		{goal("track", MetricOccurrences, 400, day(2020, 1, 1)), 300, 500, GoalOnTrack},
+		{goal("risk", MetricOccurrences, 1000, day(2020, 1, 1)), 300, 500, GoalAtRisk},
+		{goal("missed", MetricOccurrences, 1000, day(2018, 12, 31)), 300, 300, GoalMissed},
+		{goal("orgs", MetricOrganizations, 2, day(2020, 1, 1)), 2, 2, GoalMet},
+		{unis, 2, 3, GoalOnTrack},
+		{inpa, 100, 183, GoalAtRisk},
+	}
+
+	for _, tt := range tableCases {
+		report := EvaluateGoals([]Goal{tt.goal}, snapshots, now, NewOrgNormalizer())
+		got := report.Goals[0]
+		if got.Current != tt.current || math.Abs(got.Projected-tt.projected) > 1 || got.Status != tt.status {
+			t.Errorf("%s: got current %v, projected %v, %s, want %v, %v, %s", tt.goal.Name,
+				got.Current, got.Projected, got.Status, tt.current, tt.projected, tt.status)
+		}
+	}
+
+	report := EvaluateGoals([]Goal{goal("occ", MetricOccurrences, 1000, day(2020, 1, 1))}, snapshots[2:], now, NewOrgNormalizer())
+	if got := report.Goals[0]; got.Status != GoalNoHistory || got.Current != 100 {
+		t.Errorf("got %#v", got)
+	}
+	if len(report.AtRisk()) != 0 {
+		t.Errorf("got at risk %v", report.AtRisk())
+	}
+
+	steep := []*Snapshot{
+		{Taken: day(2018, 1, 1), IPTs: []IPTSnapshot{{Name: "a", Resources: []Resource{{Name: "x", Occurrences: 1}}}}},
+		{Taken: day(2019, 1, 1), IPTs: []IPTSnapshot{{Name: "a", Resources: []Resource{{Name: "x", Occurrences: 1000000}}}}},
+	}
+	far := goal("far", MetricOccurrences, 1e12, day(2200, 1, 1))
+	far.Trend = TrendExponential
+	report = EvaluateGoals([]Goal{far}, steep, now, NewOrgNormalizer())
+	if got := report.Goals[0]; !finite(got.Projected) || !finite(got.Low) || !finite(got.High) {
+		t.Errorf("got overflowing projection %v, %v to %v", got.Projected, got.Low, got.High)
+	}
+
+	report = EvaluateGoals([]Goal{goal("risk", MetricOccurrences, 1000, day(2020, 1, 1)), unis}, snapshots, now, NewOrgNormalizer())
+	buf := &bytes.Buffer{}
+	if err := report.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `Goals at 2019-01-01
+
+risk (every IPT)
+  300 of 1000 occurrences by 2020-01-01, 30%
+  projected 500, 497 to 503, 50%: at risk
+  expected by 2022-07-01
+
+unis (organizations UFRJ, USP, UNICAMP)
+  2 of 3 members with 1 resources by 2020-01-01, 67%
+  projected 3, 1 to 3, 100%: on track
+  expected by 2019-11-02
+`
+	if buf.String() != want {
+		t.Errorf("got \n%s, want \n%s", buf, want)
+	}
+}
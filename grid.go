@@ -0,0 +1,171 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Cell is a grid cell identified by the row and column of its south west
+// corner, counted in cells from latitude -90 and longitude -180.
+type Cell struct {
+	Row, Col int
+}
+
+// CellCount is the number of records of a Cell, in total and per IPT.
+type CellCount struct {
+	Cell
+	Records int
+	IPTs    map[string]int
+}
+
+// Grid bins georeferenced occurrences into square cells of Size degrees.
+type Grid struct {
+	Size  float64
+	cells map[Cell]*CellCount
+}
+
+// NewGrid returns an empty grid of cells with size degrees.
+func NewGrid(size float64) (*Grid, error) {
+	if math.IsNaN(size) || size <= 0 || size > 180 {
+		return nil, fmt.Errorf("Invalid cell size %v", size)
+	}
+	return &Grid{Size: size, cells: map[Cell]*CellCount{}}, nil
+}
+
+// CellOf returns the cell holding the point. Points at the north or east
+// limits are kept at the last cell.
+func (g *Grid) CellOf(lat, lon float64) Cell {
+	row := int(math.Floor((lat + 90) / g.Size))
+	col := int(math.Floor((lon + 180) / g.Size))
+	if max := int(math.Ceil(180/g.Size)) - 1; row > max {
+		row = max
+	}
+	if max := int(math.Ceil(360/g.Size)) - 1; col > max {
+		col = max
+	}
+	return Cell{row, col}
+}
+
+// Bounds returns the south west and north east corners of cell c, rounded to
+// hide floating point noise of sizes like 0.1.
+func (g *Grid) Bounds(c Cell) (south, west, north, east float64) {
+	round := func(f float64) float64 { return math.Floor(f*1e9+0.5) / 1e9 }
+	south = round(-90 + float64(c.Row)*g.Size)
+	west = round(-180 + float64(c.Col)*g.Size)
+	return south, west, round(math.Min(south+g.Size, 90)), round(math.Min(west+g.Size, 180))
+}
+
+// Add counts rec, published by ipt, at its cell. It reports whether rec had
+// valid coordinates.
+func (g *Grid) Add(ipt string, rec Record) bool {
+	lat, lon, ok := rec.Coordinates()
+	if !ok {
+		return false
+	}
+
+	c := g.CellOf(lat, lon)
+	count, ok := g.cells[c]
+	if !ok {
+		count = &CellCount{Cell: c, IPTs: map[string]int{}}
+		g.cells[c] = count
+	}
+	count.Records++
+	count.IPTs[ipt]++
+	return true
+}
+
+// AddArchive counts every georeferenced occurrence of the archive published
+// by ipt.
+func (g *Grid) AddArchive(ipt string, a *Archive) error {
+	return a.EachOccurrence(func(rec Record) error {
+		g.Add(ipt, rec)
+		return nil
+	})
+}
+
+// Counts returns the cells holding records sorted by row and column.
+func (g *Grid) Counts() []CellCount {
+	counts := make([]CellCount, 0, len(g.cells))
+	for _, count := range g.cells {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Row != counts[j].Row {
+			return counts[i].Row < counts[j].Row
+		}
+		return counts[i].Col < counts[j].Col
+	})
+	return counts
+}
+
+// WriteCSV writes one line per cell and IPT with the cell bounds.
+func (g *Grid) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"South", "West", "North", "East", "IPT", "Records"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, count := range g.Counts() {
+		south, west, north, east := g.Bounds(count.Cell)
+		ipts := make([]string, 0, len(count.IPTs))
+		for ipt := range count.IPTs {
+			ipts = append(ipts, ipt)
+		}
+		sort.Strings(ipts)
+		for _, ipt := range ipts {
+			line := []string{format(south), format(west), format(north), format(east), ipt, strconv.Itoa(count.IPTs[ipt])}
+			if err := out.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteGeoJSON writes the cells holding records as a FeatureCollection of
+// polygons with records and ipts properties.
+func (g *Grid) WriteGeoJSON(w io.Writer) error {
+	type geometry struct {
+		Type        string         `json:"type"`
+		Coordinates [][][2]float64 `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string                 `json:"type"`
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   geometry               `json:"geometry"`
+	}
+
+	fc := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{"FeatureCollection", []feature{}}
+
+	for _, count := range g.Counts() {
+		south, west, north, east := g.Bounds(count.Cell)
+		fc.Features = append(fc.Features, feature{
+			Type: "Feature",
+			Properties: map[string]interface{}{
+				"records": count.Records,
+				"ipts":    count.IPTs,
+			},
+			Geometry: geometry{
+				Type: "Polygon",
+				Coordinates: [][][2]float64{{
+					{west, south}, {east, south}, {east, north}, {west, north}, {west, south},
+				}},
+			},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(fc)
+}
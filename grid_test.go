@@ -0,0 +1,106 @@
+package iptReport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGridCellOf(t *testing.T) {
+	g, err := NewGrid(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tableCases := []struct {
+		lat, lon float64
+		cell     Cell
+		bounds   [4]float64
+	}{
+		{-90, -180, Cell{0, 0}, [4]float64{-90, -180, -89.5, -179.5}},
+		{-15.8, -47.9, Cell{148, 264}, [4]float64{-16, -48, -15.5, -47.5}},
+		{0, 0, Cell{180, 360}, [4]float64{0, 0, 0.5, 0.5}},
+		{90, 180, Cell{359, 719}, [4]float64{89.5, 179.5, 90, 180}},
+	}
+
+	for _, tt := range tableCases {
+		c := g.CellOf(tt.lat, tt.lon)
+		if c != tt.cell {
+			t.Errorf("%v,%v: got %v, want %v", tt.lat, tt.lon, c, tt.cell)
+		}
+		south, west, north, east := g.Bounds(c)
+		if b := [4]float64{south, west, north, east}; b != tt.bounds {
+			t.Errorf("%v: got %v, want %v", c, b, tt.bounds)
+		}
+	}
+
+	for _, size := range []float64{0, -1, 200, math.NaN()} {
+		if _, err := NewGrid(size); err == nil {
+			t.Errorf("expected error for size %v", size)
+		}
+	}
+}
+
+func TestGrid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := OpenArchive(writeArchive(t, dir, "occ.zip", testOccurrenceArchive(
+		"1\t\t\t\t\t\tAra ararauna\t-15.8\t-47.9\t\t",
+		"2\t\t\t\t\t\tAra ararauna\t-15.6\t-47.6\t\t",
+		"3\t\t\t\t\t\tPuma concolor\t-3.1\t-60.0\t\t",
+		"4\t\t\t\t\t\tPuma concolor\t\t\t\t",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	g, err := NewGrid(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddArchive("sibbr", a); err != nil {
+		t.Fatal(err)
+	}
+	g.Add("peld", Record{Terms: map[string]string{"decimalLatitude": "-15.1", "decimalLongitude": "-47.2"}})
+
+	want := []CellCount{
+		{Cell{74, 132}, 3, map[string]int{"sibbr": 2, "peld": 1}},
+		{Cell{86, 120}, 1, map[string]int{"sibbr": 1}},
+	}
+	if got := g.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got \n%#v, want \n%#v", got, want)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := g.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	csv := "South,West,North,East,IPT,Records\n" +
+		"-16,-48,-15,-47,peld,1\n" +
+		"-16,-48,-15,-47,sibbr,2\n" +
+		"-4,-60,-3,-59,sibbr,1\n"
+	if buf.String() != csv {
+		t.Errorf("got \n%s, want \n%s", buf.String(), csv)
+	}
+
+	buf.Reset()
+	if err := g.WriteGeoJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	regions := geoFeatureCollection{}
+	if err := json.Unmarshal(buf.Bytes(), &regions); err != nil {
+		t.Fatal(err)
+	}
+	if len(regions.Features) != 2 || regions.Features[0].Properties["records"] != 3.0 {
+		t.Errorf("got \n%s", buf.String())
+	}
+}
@@ -8,23 +8,31 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/zieckey/goini"
 )
 
 // IPTResult is a placeholder struct to receive data coming from crawlIPT
-// coroutines.
+// coroutines. Version is the IPT software version shown at the footer.
 type IPTResult struct {
-	Msg  [][]string
-	Name string
-	Err  error
+	Msg     [][]string
+	Name    string
+	Err     error
+	Version string
 }
 
-// Resource type will hold the final json object.
+// Resource is a resource listed at an IPT home page, see JSONResource for
+// how JSON reports write it. Records keeps the value of the records column
+// at the IPT home page, as CrawlResource overwrites Occurrences with the
+// count of the occurrence data table. DatasetKey and PublisherKey are the
+// GBIF UUIDs of registered resources.
 type Resource struct {
 	Logo            string
 	Name            string
@@ -35,22 +43,55 @@ type Resource struct {
 	Events          int
 	Measurements    int
 	Occurrences     int
+	Records         int
 	LastModified    time.Time
 	LastPublication time.Time
 	NextPublication time.Time
 	Visibility      string
 	Author          string
+	DatasetKey      string
+	PublisherKey    string
 }
 
-// IPT is our main struct to describe each IPT.
+// IPT is our main struct to describe each IPT. BindErrs holds one BindError
+// for each resource that failed to bind. Unchanged holds the shortnames of
+// resources an incremental crawl reused from the previous run. Elapsed is how
+// long crawling the IPT and its resources took.
 type IPT struct {
 	Name      string
+	URL       string
+	Version   string
 	Resources []Resource
 	Err       error
+	BindErrs  []error
+	Unchanged map[string]bool
+	Elapsed   time.Duration
+}
+
+// BindError records a resource row which Bind could not handle. The partially
+// bound resource is still kept at IPT.Resources.
+type BindError struct {
+	Resource string
+	Err      error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Resource, e.Err)
 }
 
 // Bind bind all unmarshal elements to Resource.
-func (r *Resource) Bind(resource []string) (err error) {
+func (r *Resource) Bind(resource []string) error {
+	detailed, err := r.BindRow(resource)
+	if err != nil || !detailed {
+		return err
+	}
+	return r.CrawlResource()
+}
+
+// BindRow binds the elements of a home page row without crawling the
+// resource page. detailed reports whether the records column links to a
+// resource page which CrawlResource should visit.
+func (r *Resource) BindRow(resource []string) (detailed bool, err error) {
 	regLogo := regexp.MustCompile(`src\s*=\s*"([^"]+)`)
 	if match := regLogo.FindStringSubmatch(resource[0]); match != nil {
 		r.Logo = match[1]
@@ -72,49 +113,105 @@ func (r *Resource) Bind(resource []string) (err error) {
 
 	regRecords := regexp.MustCompile(`.+?>([^<]+).+`)
 	if match := regRecords.FindStringSubmatch(resource[5]); match != nil {
-		r.Occurrences, err = strconv.Atoi(strings.Replace(match[1], ",", "", -1))
+		r.Records, err = strconv.Atoi(strings.Replace(match[1], ",", "", -1))
 		if err != nil {
-			return err
-		}
-		if err = r.CrawlResource(); err != nil {
-			return err
+			return false, err
 		}
+		r.Occurrences = r.Records
+		detailed = true
 
 	} else {
-		r.Occurrences, err = strconv.Atoi(strings.Replace(resource[5], ",", "", -1))
+		r.Records, err = strconv.Atoi(strings.Replace(resource[5], ",", "", -1))
 		if err != nil {
-			return err
+			return false, err
 		}
+		r.Occurrences = r.Records
 	}
 
 	if resource[6] != "--" {
 		r.LastModified, err = time.Parse("2006-01-02", resource[6])
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 	if resource[7] != "--" {
 		r.LastPublication, err = time.Parse("2006-01-02", resource[7])
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 	if resource[8] != "--" {
 		r.NextPublication, err = time.Parse("2006-01-02 15:04:05", resource[8])
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
 	r.Author = resource[9]
 	r.Visibility = resource[10]
 
-	return nil
+	return detailed, nil
+}
+
+// Shortname returns the r parameter of the resource link, which the IPT uses
+// to identify the resource. Empty if the link has none.
+func (r Resource) Shortname() string {
+	u, err := url.Parse(r.Link)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("r")
+}
+
+// ResourceKey identifies a resource across crawls, renames and IPT aliases:
+// the IPT base url without scheme and trailing slash followed by the r
+// shortname, e.g. ipt.sibbr.gov.br/peld?r=diversidade.
+type ResourceKey string
+
+// NewResourceKey returns the key of the resource shortname of the IPT at
+// iptURL. Empty if either is.
+func NewResourceKey(iptURL, shortname string) ResourceKey {
+	if iptURL == "" || shortname == "" {
+		return ""
+	}
+	return ResourceKey(normalizeURL(iptURL) + "?r=" + shortname)
+}
+
+// Key returns the ResourceKey derived from the resource link, empty if the
+// link isn't an IPT page of the resource.
+func (r Resource) Key() ResourceKey {
+	return NewResourceKey(instanceURL(r.Link), r.Shortname())
+}
+
+// ArchiveURL returns where the IPT serves the latest DwC-A of the resource,
+// derived from the resource link. Empty if the link isn't a resource page.
+func (r Resource) ArchiveURL() string {
+	u, err := url.Parse(r.Link)
+	if err != nil || u.Query().Get("r") == "" {
+		return ""
+	}
+	i := strings.LastIndex(u.Path, "/")
+	if page := u.Path[i+1:]; page != "resource" && page != "resource.do" {
+		return ""
+	}
+	u.Path = u.Path[:i+1] + "archive.do"
+	u.RawQuery = url.Values{"r": {u.Query().Get("r")}}.Encode()
+	return u.String()
+}
+
+// VersionArchiveURL returns where the IPT serves the DwC-A of the given
+// published version of the resource, e.g. 1.2.
+func (r Resource) VersionArchiveURL(version string) string {
+	archive := r.ArchiveURL()
+	if archive == "" {
+		return ""
+	}
+	return archive + "&" + url.Values{"v": {version}}.Encode()
 }
 
 // CrawlResource seek information about number of occurreces, events and
 // measurements to fill Resource.occurreces, Resource.Events and
-// Resource.Measurements.
+// Resource.Measurements, as well as the GBIF keys of registered resources.
 func (r *Resource) CrawlResource() (err error) {
 
 	doc, err := goquery.NewDocument(r.Link)
@@ -148,6 +245,18 @@ func (r *Resource) CrawlResource() (err error) {
 
 	})
 
+	regKey := regexp.MustCompile(`gbif\.org/(dataset|publisher)/([0-9a-f-]{36})`)
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		if match := regKey.FindStringSubmatch(href); match != nil {
+			if match[1] == "dataset" {
+				r.DatasetKey = match[2]
+			} else if r.PublisherKey == "" {
+				r.PublisherKey = match[2]
+			}
+		}
+	})
+
 	return
 }
 
@@ -164,6 +273,9 @@ func EscapeJSON(s string) string {
 	return s
 }
 
+// regVersion finds the IPT version in the footer of its home page.
+var regVersion = regexp.MustCompile(`IPT Version\s+([0-9][^<\s]*)`)
+
 // CrawlIPT crawl ipt at url with identifying alias storing in IPTResult.
 func CrawlIPT(url, alias string, result chan IPTResult) {
 	resp, err := http.Get(url)
@@ -186,10 +298,95 @@ func CrawlIPT(url, alias string, result chan IPTResult) {
 			result <- IPTResult{Msg: nil, Name: alias, Err: err}
 			return
 		}
-		result <- IPTResult{Msg: resources, Name: alias, Err: nil}
+		version := ""
+		if match := regVersion.FindStringSubmatch(string(body)); match != nil {
+			version = match[1]
+		}
+		result <- IPTResult{Msg: resources, Name: alias, Err: nil, Version: version}
 		return
 	}
 
 	result <- IPTResult{Msg: nil, Name: alias, Err: fmt.Errorf("No json found at %s", url)}
 
 }
+
+// NewIPT binds every resource of a crawled IPT. Resources failing to bind are
+// kept and their errors stored at IPT.BindErrs.
+func NewIPT(result IPTResult, url string) IPT {
+	ipt := IPT{Name: result.Name, URL: url, Version: result.Version}
+	if result.Err != nil {
+		ipt.Err = result.Err
+		return ipt
+	}
+	for _, resource := range result.Msg {
+		col := Resource{}
+		if err := col.Bind(resource); err != nil {
+			ipt.BindErrs = append(ipt.BindErrs, &BindError{Resource: col.Name, Err: err})
+		}
+		ipt.Resources = append(ipt.Resources, col)
+	}
+	return ipt
+}
+
+// Crawl crawls concurrently every IPT at ipts, a map of alias to url, and
+// returns them sorted by alias.
+func Crawl(ipts map[string]string) []IPT {
+	return crawlAll(ipts, NewIPT)
+}
+
+// crawlAll crawls concurrently the home page of every IPT at ipts and binds
+// its resources with bind, timing both at IPT.Elapsed. IPTs are returned
+// sorted by alias.
+func crawlAll(ipts map[string]string, bind func(result IPTResult, url string) IPT) []IPT {
+	crawled := make(chan IPT)
+	for alias, url := range ipts {
+		go func(alias, url string) {
+			crawled <- crawlOne(alias, url, bind)
+		}(alias, url)
+	}
+
+	IPTs := make([]IPT, 0, len(ipts))
+	for range ipts {
+		IPTs = append(IPTs, <-crawled)
+	}
+	SortIPTs(IPTs)
+
+	return IPTs
+}
+
+// crawlOne crawls the home page of the IPT at url and binds its resources
+// with bind, timing both at IPT.Elapsed.
+func crawlOne(alias, url string, bind func(result IPTResult, url string) IPT) IPT {
+	start := time.Now()
+	result := make(chan IPTResult, 1)
+	CrawlIPT(url, alias, result)
+	ipt := bind(<-result, url)
+	ipt.Elapsed = time.Since(start)
+	return ipt
+}
+
+// SortIPTs sorts IPTs by alias.
+func SortIPTs(IPTs []IPT) {
+	sort.Slice(IPTs, func(i, j int) bool { return IPTs[i].Name < IPTs[j].Name })
+}
+
+// ReadIPTs reads an ini file where each section is an IPT alias holding its
+// url, e.g.:
+//
+//	[gbif]
+//	url=https://ipt.gbif.org/
+func ReadIPTs(path string) (map[string]string, error) {
+	ini := goini.New()
+	if err := ini.ParseFile(path); err != nil {
+		return nil, err
+	}
+
+	ipts := map[string]string{}
+	for alias, ipt := range ini.GetAll() {
+		if alias == "" {
+			continue
+		}
+		ipts[alias] = ipt["url"]
+	}
+	return ipts, nil
+}
@@ -2,6 +2,7 @@ package iptReport
 
 import (
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -38,6 +39,7 @@ func TestBind(t *testing.T) {
 				Type:            "Occurrence",
 				Subtype:         "--",
 				Occurrences:     3537502,
+				Records:         3537502,
 				LastModified:    time.Date(2017, time.August, 7, 0, 0, 0, 0, time.UTC),
 				LastPublication: time.Date(2017, time.August, 7, 0, 0, 0, 0, time.UTC),
 				NextPublication: time.Date(2018, time.August, 4, 11, 45, 15, 0, time.UTC),
@@ -173,7 +175,8 @@ func TestCrawIPT(t *testing.T) {
 				},
 			},
 				"goeldi",
-				nil},
+				nil,
+				"2.3.4-r68469e8"},
 			false},
 		{
 			"THIS URL SHOULDN'T EXIST",
@@ -227,6 +230,8 @@ func TestCrawlResource(t *testing.T) {
 				Events:       474,
 				Measurements: 4019,
 				Occurrences:  4019,
+				DatasetKey:   "32ba1b9a-b06d-417e-98ea-eb0cfb67466a",
+				PublisherKey: "f5fd374b-89cb-4ab6-b3eb-794c65f232c3",
 			},
 			false,
 		},
@@ -293,3 +298,73 @@ func TestEscapeJSON(t *testing.T) {
 	}
 
 }
+
+func TestArchiveURL(t *testing.T) {
+
+	tableCases := []struct {
+		input     Resource
+		shortname string
+		key       ResourceKey
+		archive   string
+	}{
+		{
+			Resource{Link: "https://ipt.sibbr.gov.br/repatriados/resource?r=repatriados"},
+			"repatriados",
+			"ipt.sibbr.gov.br/repatriados?r=repatriados",
+			"https://ipt.sibbr.gov.br/repatriados/archive.do?r=repatriados",
+		},
+		{
+			Resource{Link: "http://IPT.sibbr.gov.br/peld/resource.do?r=diversidade&v=1.2"},
+			"diversidade",
+			"ipt.sibbr.gov.br/peld?r=diversidade",
+			"http://IPT.sibbr.gov.br/peld/archive.do?r=diversidade",
+		},
+		{
+			Resource{Link: "https://ipt.sibbr.gov.br/peld/about.do"},
+			"",
+			"",
+			"",
+		},
+	}
+
+	for _, tt := range tableCases {
+		if r := tt.input.Shortname(); r != tt.shortname {
+			t.Errorf("got %s, want %s", r, tt.shortname)
+		}
+		if r := tt.input.Key(); r != tt.key {
+			t.Errorf("got key %s, want %s", r, tt.key)
+		}
+		if r := tt.input.ArchiveURL(); r != tt.archive {
+			t.Errorf("got %s, want %s", r, tt.archive)
+		}
+		if r := tt.input.VersionArchiveURL("1.1"); tt.archive != "" && r != tt.archive+"&v=1.1" {
+			t.Errorf("got %s, want %s&v=1.1", r, tt.archive)
+		}
+	}
+}
+
+func TestReadIPTs(t *testing.T) {
+	file, err := ioutil.TempFile("", "ipts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("[gbif]\nurl=https://ipt.gbif.org/\n[peld]\nurl=https://ipt.sibbr.gov.br/peld\n")
+	file.Close()
+
+	ipts, err := ReadIPTs(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"gbif": "https://ipt.gbif.org/",
+		"peld": "https://ipt.sibbr.gov.br/peld",
+	}
+	if !reflect.DeepEqual(ipts, want) {
+		t.Errorf("got \n%#v, want \n%#v", ipts, want)
+	}
+
+	if _, err := ReadIPTs("THIS FILE SHOULDN'T EXIST"); err == nil {
+		t.Error("expected error reading missing file")
+	}
+}
@@ -0,0 +1,207 @@
+package iptReport
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// JSONSchemaVersion is the version of the JSON report schema, the major part
+// changing on incompatible changes. Reports carry it as schema_version.
+const JSONSchemaVersion = "1.0.0"
+
+// JSONSchemaID identifies JSONSchema, also published at
+// schema/report.schema.json.
+const JSONSchemaID = "https://github.com/dvdscripter/iptReport/schema/report.schema.json"
+
+// JSONSchema is the JSON Schema of the documents written by WriteJSON. Each
+// line written by WriteNDJSON is an ipt definition.
+const JSONSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/dvdscripter/iptReport/schema/report.schema.json",
+  "title": "IPT report",
+  "description": "Resources published at Integrated Publishing Toolkit instances, version 1.0.0.",
+  "type": "object",
+  "required": ["schema_version", "generated", "ipts"],
+  "properties": {
+    "schema_version": {"type": "string", "pattern": "^1\\."},
+    "generated": {"type": "string", "format": "date-time"},
+    "ipts": {"type": "array", "items": {"$ref": "#/definitions/ipt"}}
+  },
+  "definitions": {
+    "date": {
+      "description": "RFC 3339 date and time, null when unknown.",
+      "oneOf": [{"type": "string", "format": "date-time"}, {"type": "null"}]
+    },
+    "error": {
+      "type": "object",
+      "required": ["message"],
+      "properties": {
+        "resource": {"type": "string", "description": "Home page row of the resource which failed to bind."},
+        "message": {"type": "string"}
+      }
+    },
+    "resource": {
+      "type": "object",
+      "required": ["key", "shortname", "name", "link", "logo", "organization", "type", "subtype",
+        "events", "measurements", "occurrences", "records", "last_modified", "last_publication",
+        "next_publication", "visibility", "author", "dataset_key", "publisher_key"],
+      "properties": {
+        "key": {"type": "string", "description": "Identifies the resource across crawls, see ResourceKey."},
+        "shortname": {"type": "string"},
+        "name": {"type": "string"},
+        "link": {"type": "string"},
+        "logo": {"type": "string"},
+        "organization": {"type": "string"},
+        "type": {"type": "string"},
+        "subtype": {"type": "string"},
+        "events": {"type": "integer"},
+        "measurements": {"type": "integer"},
+        "occurrences": {"type": "integer"},
+        "records": {"type": "integer"},
+        "last_modified": {"$ref": "#/definitions/date"},
+        "last_publication": {"$ref": "#/definitions/date"},
+        "next_publication": {"$ref": "#/definitions/date"},
+        "visibility": {"type": "string"},
+        "author": {"type": "string"},
+        "dataset_key": {"type": ["string", "null"], "description": "GBIF dataset UUID, null when not registered."},
+        "publisher_key": {"type": ["string", "null"], "description": "GBIF publisher UUID, null when not registered."}
+      }
+    },
+    "ipt": {
+      "type": "object",
+      "required": ["name", "url", "version", "elapsed_seconds", "error", "bind_errors", "resources"],
+      "properties": {
+        "name": {"type": "string", "description": "Alias of the IPT at ipts.ini."},
+        "url": {"type": "string"},
+        "version": {"type": ["string", "null"], "description": "IPT software version, null when unknown."},
+        "elapsed_seconds": {"type": "number"},
+        "error": {"oneOf": [{"$ref": "#/definitions/error"}, {"type": "null"}], "description": "Why the IPT couldn't be crawled."},
+        "bind_errors": {"type": "array", "items": {"$ref": "#/definitions/error"}},
+        "resources": {"type": "array", "items": {"$ref": "#/definitions/resource"}}
+      }
+    }
+  }
+}
+`
+
+// jsonTime marshals as RFC 3339, or null when zero.
+type jsonTime time.Time
+
+func (t jsonTime) MarshalJSON() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(time.Time(t).Format(time.RFC3339))
+}
+
+// nullString returns nil for empty strings, marshaled as null.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// JSONError is an error as written in JSON reports. Resource names the row
+// of bind errors.
+type JSONError struct {
+	Resource string `json:"resource,omitempty"`
+	Message  string `json:"message"`
+}
+
+// newJSONError returns err as a JSONError, nil for nil.
+func newJSONError(err error) *JSONError {
+	if err == nil {
+		return nil
+	}
+	if b, ok := err.(*BindError); ok {
+		return &JSONError{Resource: b.Resource, Message: b.Err.Error()}
+	}
+	return &JSONError{Message: err.Error()}
+}
+
+// JSONResource is a Resource as written in JSON reports.
+type JSONResource struct {
+	Key             ResourceKey `json:"key"`
+	Shortname       string      `json:"shortname"`
+	Name            string      `json:"name"`
+	Link            string      `json:"link"`
+	Logo            string      `json:"logo"`
+	Organization    string      `json:"organization"`
+	Type            string      `json:"type"`
+	Subtype         string      `json:"subtype"`
+	Events          int         `json:"events"`
+	Measurements    int         `json:"measurements"`
+	Occurrences     int         `json:"occurrences"`
+	Records         int         `json:"records"`
+	LastModified    jsonTime    `json:"last_modified"`
+	LastPublication jsonTime    `json:"last_publication"`
+	NextPublication jsonTime    `json:"next_publication"`
+	Visibility      string      `json:"visibility"`
+	Author          string      `json:"author"`
+	DatasetKey      *string     `json:"dataset_key"`
+	PublisherKey    *string     `json:"publisher_key"`
+}
+
+// NewJSONResource returns r as written in JSON reports.
+func NewJSONResource(r Resource) JSONResource {
+	return JSONResource{Key: r.Key(), Shortname: r.Shortname(), Name: r.Name, Link: r.Link, Logo: r.Logo,
+		Organization: r.Organization, Type: r.Type, Subtype: r.Subtype, Events: r.Events,
+		Measurements: r.Measurements, Occurrences: r.Occurrences, Records: r.Records,
+		LastModified: jsonTime(r.LastModified), LastPublication: jsonTime(r.LastPublication),
+		NextPublication: jsonTime(r.NextPublication), Visibility: r.Visibility, Author: r.Author,
+		DatasetKey: nullString(r.DatasetKey), PublisherKey: nullString(r.PublisherKey)}
+}
+
+// JSONIPT is an IPT as written in JSON reports, nesting its resources.
+type JSONIPT struct {
+	Name      string         `json:"name"`
+	URL       string         `json:"url"`
+	Version   *string        `json:"version"`
+	Elapsed   float64        `json:"elapsed_seconds"`
+	Err       *JSONError     `json:"error"`
+	BindErrs  []JSONError    `json:"bind_errors"`
+	Resources []JSONResource `json:"resources"`
+}
+
+// NewJSONIPT returns ipt as written in JSON reports.
+func NewJSONIPT(ipt IPT) JSONIPT {
+	j := JSONIPT{Name: ipt.Name, URL: ipt.URL, Version: nullString(ipt.Version), Elapsed: ipt.Elapsed.Seconds(),
+		Err: newJSONError(ipt.Err), BindErrs: []JSONError{}, Resources: []JSONResource{}}
+	for _, err := range ipt.BindErrs {
+		j.BindErrs = append(j.BindErrs, *newJSONError(err))
+	}
+	for _, r := range ipt.Resources {
+		j.Resources = append(j.Resources, NewJSONResource(r))
+	}
+	return j
+}
+
+// JSONReport is the document written by WriteJSON, see JSONSchema.
+type JSONReport struct {
+	SchemaVersion string    `json:"schema_version"`
+	Generated     jsonTime  `json:"generated"`
+	IPTs          []JSONIPT `json:"ipts"`
+}
+
+// NewJSONReport returns the JSON report of ipts generated at generated.
+func NewJSONReport(ipts []IPT, generated time.Time) JSONReport {
+	report := JSONReport{SchemaVersion: JSONSchemaVersion, Generated: jsonTime(generated), IPTs: []JSONIPT{}}
+	for _, ipt := range ipts {
+		report.IPTs = append(report.IPTs, NewJSONIPT(ipt))
+	}
+	return report
+}
+
+// WriteJSON writes the report of ipts generated at generated as an indented
+// json object.
+func WriteJSON(w io.Writer, ipts []IPT, generated time.Time) error {
+	return WriteReport(NewJSONWriter(w, generated), ipts)
+}
+
+// WriteNDJSON writes a line per IPT, with its resources, as newline
+// delimited json.
+func WriteNDJSON(w io.Writer, ipts []IPT) error {
+	return WriteReport(NewNDJSONWriter(w), ipts)
+}
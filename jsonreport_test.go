@@ -0,0 +1,133 @@
+package iptReport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJSON(t *testing.T) {
+	generated := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	ipts := []IPT{
+		{Name: "a", URL: "http://ipt.example.org", Version: "2.3.4", Elapsed: 1500 * time.Millisecond,
+			Resources: []Resource{{Name: "Birds", Link: "http://ipt.example.org/resource?r=birds", Occurrences: 10,
+				LastPublication: time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC), DatasetKey: "d-1"}},
+			BindErrs: []error{&BindError{Resource: "Fish", Err: errors.New("bad date")}}},
+		{Name: "b", URL: "http://down.example.org", Err: errors.New("connection refused")},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, ipts, generated); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["schema_version"] != JSONSchemaVersion || doc["generated"] != "2018-06-01T12:00:00Z" {
+		t.Errorf("got %v", doc)
+	}
+
+	a := doc["ipts"].([]interface{})[0].(map[string]interface{})
+	r := a["resources"].([]interface{})[0].(map[string]interface{})
+	tableCases := []struct {
+		field  string
+		output interface{}
+	}{
+		{"key", "ipt.example.org?r=birds"},
+		{"shortname", "birds"},
+		{"occurrences", 10.0},
+		{"last_publication", "2018-05-02T00:00:00Z"},
+		{"last_modified", nil},
+		{"next_publication", nil},
+		{"dataset_key", "d-1"},
+		{"publisher_key", nil},
+	}
+	for _, tt := range tableCases {
+		if got, ok := r[tt.field]; !ok || !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("%s: got %v, want %v", tt.field, got, tt.output)
+		}
+	}
+	if a["version"] != "2.3.4" || a["elapsed_seconds"] != 1.5 || a["error"] != nil {
+		t.Errorf("got ipt %v", a)
+	}
+	bindErr := a["bind_errors"].([]interface{})[0].(map[string]interface{})
+	if bindErr["resource"] != "Fish" || bindErr["message"] != "bad date" {
+		t.Errorf("got bind error %v", bindErr)
+	}
+
+	b := doc["ipts"].([]interface{})[1].(map[string]interface{})
+	if b["version"] != nil || len(b["resources"].([]interface{})) != 0 ||
+		b["error"].(map[string]interface{})["message"] != "connection refused" {
+		t.Errorf("got ipt %v", b)
+	}
+
+	buf.Reset()
+	if err := WriteNDJSON(buf, ipts); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var line JSONIPT
+	if err := json.Unmarshal([]byte(lines[1]), &line); err != nil || line.Name != "b" {
+		t.Errorf("got %s, %v", lines[1], err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	published, err := ioutil.ReadFile("schema/report.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(published) != JSONSchema {
+		t.Error("schema/report.schema.json differs from JSONSchema")
+	}
+
+	var schema struct {
+		ID          string `json:"$id"`
+		Definitions map[string]struct {
+			Required []string `json:"required"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal([]byte(JSONSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.ID != JSONSchemaID {
+		t.Errorf("got $id %s, want %s", schema.ID, JSONSchemaID)
+	}
+
+	// the fields written are the ones the schema requires
+	fields := func(v interface{}) []string {
+		data, _ := json.Marshal(v)
+		m := map[string]interface{}{}
+		json.Unmarshal(data, &m)
+		keys := []string{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	tableCases := []struct {
+		definition string
+		value      interface{}
+	}{
+		{"resource", NewJSONResource(Resource{})},
+		{"ipt", NewJSONIPT(IPT{})},
+	}
+	for _, tt := range tableCases {
+		required := append([]string{}, schema.Definitions[tt.definition].Required...)
+		sort.Strings(required)
+		if got := fields(tt.value); !reflect.DeepEqual(got, required) {
+			t.Errorf("%s: got fields %v, schema requires %v", tt.definition, got, required)
+		}
+	}
+}
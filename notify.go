@@ -0,0 +1,498 @@
+package iptReport
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/zieckey/goini"
+)
+
+// SignatureHeader holds the hex HMAC-SHA256 of webhook bodies, prefixed by
+// "sha256=".
+const SignatureHeader = "X-IPTReport-Signature"
+
+// Default templates of notifications, executed on a Notification.
+const (
+	DefaultSubjectTemplate = `IPT alerts for {{.Owner}}: {{len .Alerts}} changed`
+	DefaultTextTemplate    = `{{range .Alerts}}{{.Describe}}
+{{end}}`
+)
+
+// Notification is a message to Owner about Alerts, or a report without
+// them.
+type Notification struct {
+	Owner   string
+	Subject string
+	Text    string
+	Alerts  []Alert
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// SMTPNotifier emails notifications from From to To through the SMTP server
+// at Addr, host:port, authenticating with Auth when set.
+type SMTPNotifier struct {
+	Addr string
+	From string
+	To   []string
+	Auth smtp.Auth
+}
+
+// mailSubject returns subject as a header value: line breaks, which would
+// start other headers, are replaced by spaces and non-ASCII text is encoded
+// as RFC 2047 words.
+func mailSubject(subject string) string {
+	subject = strings.Join(strings.FieldsFunc(subject, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+	return mime.QEncoding.Encode("utf-8", subject)
+}
+
+// Notify sends n as a plain text email.
+func (s *SMTPNotifier) Notify(n Notification) error {
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", mailSubject(n.Subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprint(msg, "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprint(msg, strings.Replace(n.Text, "\n", "\r\n", -1))
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
+
+// post sends body as json to url, failing on statuses other than 2xx.
+func post(client *http.Client, url string, body []byte, header http.Header) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	// read through so the connection is reused
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		return fmt.Errorf("POST %s: %v", url, err)
+	}
+	return nil
+}
+
+// webhookAlert is an Alert as posted by WebhookNotifier.
+type webhookAlert struct {
+	Rule         string      `json:"rule"`
+	Severity     string      `json:"severity"`
+	Status       string      `json:"status"`
+	IPT          string      `json:"ipt"`
+	Resource     ResourceKey `json:"resource,omitempty"`
+	Organization string      `json:"organization,omitempty"`
+	Message      string      `json:"message"`
+	FirstSeen    time.Time   `json:"first_seen"`
+	LastSeen     time.Time   `json:"last_seen"`
+}
+
+// webhookPayload is the body posted by WebhookNotifier.
+type webhookPayload struct {
+	Owner   string         `json:"owner"`
+	Subject string         `json:"subject"`
+	Text    string         `json:"text"`
+	Alerts  []webhookAlert `json:"alerts"`
+}
+
+// WebhookNotifier posts notifications as json to URL, signing the body with
+// Secret in SignatureHeader when set.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Sign returns the signature of body with secret as sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify posts n.
+func (wh *WebhookNotifier) Notify(n Notification) error {
+	payload := webhookPayload{Owner: n.Owner, Subject: n.Subject, Text: n.Text, Alerts: []webhookAlert{}}
+	for _, a := range n.Alerts {
+		payload.Alerts = append(payload.Alerts, webhookAlert{Rule: a.Rule, Severity: a.Severity, Status: a.Status,
+			IPT: a.IPT, Resource: a.Resource, Organization: a.Organization, Message: a.Message,
+			FirstSeen: a.FirstSeen, LastSeen: a.LastSeen})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if wh.Secret != "" {
+		header.Set(SignatureHeader, Sign(wh.Secret, body))
+	}
+	return post(wh.Client, wh.URL, body, header)
+}
+
+// ChatNotifier posts notifications to a Slack or Mattermost incoming webhook
+// at URL, to Channel as Username when set.
+type ChatNotifier struct {
+	URL      string
+	Channel  string
+	Username string
+	Client   *http.Client
+}
+
+// Notify posts n as a chat message, the subject in bold.
+func (c *ChatNotifier) Notify(n Notification) error {
+	payload := struct {
+		Text     string `json:"text"`
+		Channel  string `json:"channel,omitempty"`
+		Username string `json:"username,omitempty"`
+	}{"*" + n.Subject + "*\n" + n.Text, c.Channel, c.Username}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(c.Client, c.URL, body, nil)
+}
+
+// RetryNotifier retries failed notifications up to Attempts times, waiting
+// Wait after the first failure and doubling it after each other.
+type RetryNotifier struct {
+	Notifier Notifier
+	Attempts int
+	Wait     time.Duration
+}
+
+// Notify delivers n, returning the last error when every attempt failed.
+func (r *RetryNotifier) Notify(n Notification) error {
+	var err error
+	wait := r.Wait
+	for i := 0; i < r.Attempts || i == 0; i++ {
+		if i > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		if err = r.Notifier.Notify(n); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// NotificationTemplate renders notifications from their subject and text
+// templates.
+type NotificationTemplate struct {
+	Subject *template.Template
+	Text    *template.Template
+}
+
+// NewNotificationTemplate parses the subject and text templates, empty ones
+// being the defaults.
+func NewNotificationTemplate(subject, text string) (*NotificationTemplate, error) {
+	if subject == "" {
+		subject = DefaultSubjectTemplate
+	}
+	if text == "" {
+		text = DefaultTextTemplate
+	}
+	s, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New("text").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationTemplate{Subject: s, Text: t}, nil
+}
+
+// Render returns the notification of alerts to owner.
+func (t *NotificationTemplate) Render(owner string, alerts []Alert) (Notification, error) {
+	n := Notification{Owner: owner, Alerts: alerts}
+	buf := &bytes.Buffer{}
+	if err := t.Subject.Execute(buf, n); err != nil {
+		return n, err
+	}
+	n.Subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := t.Text.Execute(buf, n); err != nil {
+		return n, err
+	}
+	n.Text = buf.String()
+	return n, nil
+}
+
+// Owner is a publisher notified by Notifiers of the alerts of its IPTs, by
+// alias, and of the resources of its Organizations. An IPT "*" owns every
+// alert.
+type Owner struct {
+	Name          string
+	IPTs          []string
+	Organizations []string
+	Notifiers     []Notifier
+}
+
+// Owns reports whether the alert is about the IPTs or organizations of the
+// owner, comparing organizations by their key in orgs.
+func (o Owner) Owns(a Alert, orgs *OrgNormalizer) bool {
+	for _, ipt := range o.IPTs {
+		if ipt == "*" || ipt == a.IPT {
+			return true
+		}
+	}
+	if a.Organization == "" {
+		return false
+	}
+	for _, org := range o.Organizations {
+		if orgs.Key(org) == orgs.Key(a.Organization) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the IPTs of the owner with all their resources, and the
+// resources of its organizations in other IPTs, comparing organizations by
+// their key in orgs.
+func (o Owner) Filter(ipts []IPT, orgs *OrgNormalizer) []IPT {
+	owned := []IPT{}
+	for _, ipt := range ipts {
+		if o.Owns(Alert{IPT: ipt.Name}, orgs) {
+			owned = append(owned, ipt)
+			continue
+		}
+		resources := []Resource{}
+		for _, r := range ipt.Resources {
+			if o.Owns(Alert{IPT: ipt.Name, Organization: r.Organization}, orgs) {
+				resources = append(resources, r)
+			}
+		}
+		if len(resources) > 0 {
+			ipt.Resources = resources
+			owned = append(owned, ipt)
+		}
+	}
+	return owned
+}
+
+// notify delivers n through every notifier of the owner.
+func (o Owner) notify(n Notification) []error {
+	errs := []error{}
+	for _, notifier := range o.Notifiers {
+		if err := notifier.Notify(n); err != nil {
+			errs = append(errs, fmt.Errorf("Owner %s: %v", o.Name, err))
+		}
+	}
+	return errs
+}
+
+// NotifyConfig routes alerts and reports to their owners, alerts rendered by
+// Template, organizations compared by their key in Orgs.
+type NotifyConfig struct {
+	Template *NotificationTemplate
+	Owners   []Owner
+	Orgs     *OrgNormalizer
+}
+
+// splitList splits a list of values separated by ";".
+func splitList(list string) []string {
+	values := []string{}
+	for _, v := range strings.Split(list, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// ReadNotifyConfig reads an ini file where each section is an owner, e.g.:
+//
+//	smtp=localhost:25
+//	from=iptreport@example.org
+//	retries=3
+//	subject=IPT alerts for {{.Owner}}
+//	template=alerts.tmpl
+//	aliases=aliases.ini
+//
+//	[INPA]
+//	ipts=inpa; inpa2
+//	organizations=Instituto Nacional de Pesquisas da Amazônia
+//	email=curator@inpa.gov.br
+//	webhook=https://inpa.gov.br/hooks/ipt
+//	secret=s3cret
+//	chat=https://chat.inpa.gov.br/hooks/abc
+//	channel=ipt
+//
+// Lists are separated by ";". The default section holds the SMTP server,
+// sender, SMTP user and password when needed, how many times to retry
+// deliveries, waiting wait between them, the subject template, the path of
+// the text template and of the organization aliases, see ReadOrgAliases.
+func ReadNotifyConfig(path string) (*NotifyConfig, error) {
+	ini := goini.New()
+	if err := ini.ParseFile(path); err != nil {
+		return nil, err
+	}
+	all := ini.GetAll()
+	defaults := all[goini.DefaultSection]
+
+	text := ""
+	if file := defaults["template"]; file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	tmpl, err := NewNotificationTemplate(defaults["subject"], text)
+	if err != nil {
+		return nil, err
+	}
+	config := &NotifyConfig{Template: tmpl, Orgs: NewOrgNormalizer()}
+	if file := defaults["aliases"]; file != "" {
+		if config.Orgs, err = ReadOrgAliases(file); err != nil {
+			return nil, err
+		}
+	}
+
+	retries, wait := 0, time.Minute
+	if v, ok := defaults["retries"]; ok {
+		if retries, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("Invalid retries %q", v)
+		}
+	}
+	if v, ok := defaults["wait"]; ok {
+		if wait, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("Invalid wait %q", v)
+		}
+	}
+	var auth smtp.Auth
+	if user := defaults["user"]; user != "" {
+		host := defaults["smtp"]
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", user, defaults["password"], host)
+	}
+
+	for name, kv := range all {
+		if name == goini.DefaultSection {
+			continue
+		}
+		owner := Owner{Name: name, IPTs: splitList(kv["ipts"]), Organizations: splitList(kv["organizations"])}
+		if to := splitList(kv["email"]); len(to) > 0 {
+			if defaults["smtp"] == "" || defaults["from"] == "" {
+				return nil, fmt.Errorf("Owner %s: email needs smtp and from", name)
+			}
+			owner.Notifiers = append(owner.Notifiers, &SMTPNotifier{Addr: defaults["smtp"], From: defaults["from"], To: to, Auth: auth})
+		}
+		if url := kv["webhook"]; url != "" {
+			owner.Notifiers = append(owner.Notifiers, &WebhookNotifier{URL: url, Secret: kv["secret"]})
+		}
+		if url := kv["chat"]; url != "" {
+			owner.Notifiers = append(owner.Notifiers, &ChatNotifier{URL: url, Channel: kv["channel"], Username: kv["username"]})
+		}
+		if retries > 0 {
+			for i, n := range owner.Notifiers {
+				owner.Notifiers[i] = &RetryNotifier{Notifier: n, Attempts: retries + 1, Wait: wait}
+			}
+		}
+		config.Owners = append(config.Owners, owner)
+	}
+	sort.Slice(config.Owners, func(i, j int) bool { return config.Owners[i].Name < config.Owners[j].Name })
+
+	return config, nil
+}
+
+// Dispatch notifies each owner of its alerts, if any, after the ones it
+// couldn't be notified of before, by owner name in undelivered. It returns
+// the alerts still undelivered, to be dispatched again on the next run, and
+// the errors of failed deliveries. Owners having several notifiers are
+// notified again by all of them when any fails.
+func (c *NotifyConfig) Dispatch(alerts []Alert, undelivered map[string][]Alert) (map[string][]Alert, []error) {
+	pending := map[string][]Alert{}
+	errs := []error{}
+	for _, owner := range c.Owners {
+		owned := append([]Alert{}, undelivered[owner.Name]...)
+		for _, a := range alerts {
+			if owner.Owns(a, c.Orgs) {
+				owned = append(owned, a)
+			}
+		}
+		if len(owned) == 0 {
+			continue
+		}
+
+		n, err := c.Template.Render(owner.Name, owned)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Owner %s: %v", owner.Name, err))
+			pending[owner.Name] = owned
+			continue
+		}
+		if failed := owner.notify(n); len(failed) > 0 {
+			errs = append(errs, failed...)
+			pending[owner.Name] = owned
+		}
+	}
+	return pending, errs
+}
+
+// ErrNothingToReport is returned by the write function of DispatchReport
+// when the IPTs of an owner have nothing worth notifying, skipping it.
+var ErrNothingToReport = errors.New("Nothing to report")
+
+// DispatchReport notifies each owner owning any of ipts of the report write
+// writes of its IPTs, see Owner.Filter, unless write returns
+// ErrNothingToReport, returning the errors of failed deliveries. Unlike
+// alerts, reports which couldn't be delivered aren't kept: the next run
+// writes them again from its own crawl.
+func (c *NotifyConfig) DispatchReport(subject string, ipts []IPT, write func(w io.Writer, ipts []IPT) error) []error {
+	errs := []error{}
+	for _, owner := range c.Owners {
+		owned := owner.Filter(ipts, c.Orgs)
+		if len(owned) == 0 {
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		err := write(buf, owned)
+		if err == ErrNothingToReport {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Owner %s: %v", owner.Name, err))
+			continue
+		}
+		errs = append(errs, owner.notify(Notification{Owner: owner.Name, Subject: subject, Text: buf.String()})...)
+	}
+	return errs
+}
@@ -0,0 +1,349 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// smtpStub accepts a single SMTP session on a local port and sends the
+// message received on the returned channel.
+func smtpStub(t *testing.T) (string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan string, 1)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		in := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 stub")
+		data := []string{}
+		for {
+			line, err := in.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 stub")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := in.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data = append(data, line)
+				}
+				messages <- strings.Join(data, "")
+				reply("250 ok")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return l.Addr().String(), messages
+}
+
+var testAlerts = []Alert{
+	{Rule: "down", Severity: SeverityCritical, IPT: "a", Message: "unreachable", Status: AlertNew},
+	{Rule: "drop", Severity: SeverityWarning, IPT: "b", Resource: "ipt.example.org?r=birds", Organization: "Museu Nacional",
+		Message: "birds: occurrences dropped", Status: AlertNew},
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := smtpStub(t)
+
+	tmpl, err := NewNotificationTemplate("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := tmpl.Render("INPA", testAlerts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SMTPNotifier{Addr: addr, From: "iptreport@example.org", To: []string{"curator@example.org"}}
+	if err := s.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-messages
+	for _, want := range []string{
+		"Subject: IPT alerts for INPA: 2 changed\r\n",
+		"To: curator@example.org\r\n",
+		"NEW critical [down] a: unreachable\r\n",
+		"NEW warning [drop] b ipt.example.org?r=birds: birds: occurrences dropped\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestMailSubject(t *testing.T) {
+	tableCases := []struct {
+		input  string
+		output string
+	}{
+		{"IPT alerts for INPA", "IPT alerts for INPA"},
+		{"IPT alerts for Museu Paraense Emílio Goeldi", "=?utf-8?q?IPT_alerts_for_Museu_Paraense_Em=C3=ADlio_Goeldi?="},
+		{"alerts\r\nBcc: someone@example.org", "alerts Bcc: someone@example.org"},
+		{"alerts\n", "alerts"},
+	}
+
+	for _, tt := range tableCases {
+		if got := mailSubject(tt.input); got != tt.output {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.output)
+		}
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got webhookPayload
+	var signature string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		if signature != Sign("s3cret", body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer ts.Close()
+
+	tableCases := []struct {
+		secret      string
+		shouldError bool
+	}{
+		{"s3cret", false},
+		{"wrong", true},
+	}
+
+	for _, tt := range tableCases {
+		wh := &WebhookNotifier{URL: ts.URL, Secret: tt.secret}
+		err := wh.Notify(Notification{Owner: "INPA", Subject: "alerts", Alerts: testAlerts})
+		if (err != nil) != tt.shouldError {
+			t.Errorf("secret %s: got error %v", tt.secret, err)
+		}
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("got signature %q", signature)
+	}
+	if len(got.Alerts) != 2 || got.Alerts[1].Resource != "ipt.example.org?r=birds" || got.Owner != "INPA" {
+		t.Errorf("got payload %#v", got)
+	}
+}
+
+func TestChatNotifier(t *testing.T) {
+	var got map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	c := &ChatNotifier{URL: ts.URL, Channel: "ipt"}
+	if err := c.Notify(Notification{Subject: "alerts", Text: "a: unreachable\n"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"text": "*alerts*\na: unreachable\n", "channel": "ipt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// failingNotifier fails its first failures notifications.
+type failingNotifier struct {
+	failures int
+	calls    int
+}
+
+func (f *failingNotifier) Notify(n Notification) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestRetryNotifier(t *testing.T) {
+	tableCases := []struct {
+		failures    int
+		attempts    int
+		calls       int
+		shouldError bool
+	}{
+		{0, 3, 1, false},
+		{2, 3, 3, false},
+		{3, 3, 3, true},
+		{1, 0, 1, true},
+	}
+
+	for _, tt := range tableCases {
+		f := &failingNotifier{failures: tt.failures}
+		err := (&RetryNotifier{Notifier: f, Attempts: tt.attempts}).Notify(Notification{})
+		if (err != nil) != tt.shouldError || f.calls != tt.calls {
+			t.Errorf("%d failures, %d attempts: got %d calls, error %v", tt.failures, tt.attempts, f.calls, err)
+		}
+	}
+}
+
+// recordingNotifier keeps the notifications it gets, failing with err.
+type recordingNotifier struct {
+	got []Notification
+	err error
+}
+
+func (r *recordingNotifier) Notify(n Notification) error {
+	r.got = append(r.got, n)
+	return r.err
+}
+
+func TestReadNotifyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := `smtp=localhost:25
+from=iptreport@example.org
+retries=2
+subject={{.Owner}}: {{len .Alerts}}
+
+[admin]
+ipts=*
+chat=http://chat.example.org/hooks/x
+
+[MN]
+organizations=Museu Nacional
+email=curator@example.org; other@example.org
+webhook=http://mn.example.org/hook
+secret=s3cret
+`
+	path := filepath.Join(dir, "notify.ini")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nc, err := ReadNotifyConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nc.Owners) != 2 || nc.Owners[0].Name != "MN" || len(nc.Owners[0].Notifiers) != 2 {
+		t.Fatalf("got owners %#v", nc.Owners)
+	}
+	retry, ok := nc.Owners[0].Notifiers[0].(*RetryNotifier)
+	if !ok || retry.Attempts != 3 {
+		t.Errorf("got notifier %#v", nc.Owners[0].Notifiers[0])
+	}
+	if s, ok := retry.Notifier.(*SMTPNotifier); !ok || len(s.To) != 2 {
+		t.Errorf("got notifier %#v", retry.Notifier)
+	}
+
+	// route through recording notifiers
+	recorders := []*recordingNotifier{{}, {}}
+	for i := range nc.Owners {
+		nc.Owners[i].Notifiers = []Notifier{recorders[i]}
+	}
+	undelivered, errs := nc.Dispatch(testAlerts, nil)
+	if len(errs) > 0 || len(undelivered) > 0 {
+		t.Fatal(errs, undelivered)
+	}
+	if got := recorders[0].got; len(got) != 1 || got[0].Subject != "MN: 1" || got[0].Alerts[0].Rule != "drop" {
+		t.Errorf("MN got %#v", got)
+	}
+	if got := recorders[1].got; len(got) != 1 || got[0].Subject != "admin: 2" {
+		t.Errorf("admin got %#v", got)
+	}
+
+	ipts := []IPT{
+		{Name: "a", Resources: []Resource{{Name: "Fish", Organization: "INPA"}}},
+		{Name: "b", Resources: []Resource{{Name: "Birds", Organization: "museu nacional"}, {Name: "Bats", Organization: "INPA"}}},
+	}
+	write := func(w io.Writer, ipts []IPT) error {
+		for _, ipt := range ipts {
+			for _, r := range ipt.Resources {
+				fmt.Fprintf(w, "%s %s\n", ipt.Name, r.Name)
+			}
+		}
+		return nil
+	}
+	if errs := nc.DispatchReport("report", ipts, write); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if got := recorders[0].got; len(got) != 2 || got[1].Text != "b Birds\n" {
+		t.Errorf("MN got %#v", got)
+	}
+	if got := recorders[1].got; len(got) != 2 || got[1].Text != "a Fish\nb Birds\nb Bats\n" {
+		t.Errorf("admin got %#v", got)
+	}
+
+	fishless := func(w io.Writer, ipts []IPT) error {
+		for _, ipt := range ipts {
+			for _, r := range ipt.Resources {
+				if r.Name == "Fish" {
+					return write(w, ipts)
+				}
+			}
+		}
+		return ErrNothingToReport
+	}
+	if errs := nc.DispatchReport("report", ipts, fishless); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(recorders[0].got) != 2 || len(recorders[1].got) != 3 {
+		t.Errorf("MN got %d notifications, admin %d, want 2 and 3", len(recorders[0].got), len(recorders[1].got))
+	}
+}
+
+func TestDispatchUndelivered(t *testing.T) {
+	tmpl, err := NewNotificationTemplate("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := &recordingNotifier{err: errors.New("connection refused")}
+	nc := &NotifyConfig{Template: tmpl, Orgs: NewOrgNormalizer(),
+		Owners: []Owner{{Name: "admin", IPTs: []string{"*"}, Notifiers: []Notifier{down}}}}
+
+	undelivered, errs := nc.Dispatch(testAlerts[:1], nil)
+	if len(errs) != 1 || len(undelivered["admin"]) != 1 {
+		t.Fatalf("got undelivered %v, errors %v", undelivered, errs)
+	}
+
+	down.err = nil
+	undelivered, errs = nc.Dispatch(testAlerts[1:], undelivered)
+	if len(errs) > 0 || len(undelivered) > 0 {
+		t.Fatalf("got undelivered %v, errors %v", undelivered, errs)
+	}
+	if got := down.got[1].Alerts; len(got) != len(testAlerts) || got[0].ID() != testAlerts[0].ID() {
+		t.Errorf("got redelivered %#v", got)
+	}
+}
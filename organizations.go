@@ -0,0 +1,174 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// NormalizeOrganization returns the key grouping trivially different
+// spellings of an organization name: case, accents, surrounding punctuation
+// and repeated spaces are ignored.
+func NormalizeOrganization(name string) string {
+	name = strings.TrimFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) })
+	return FoldAccents(strings.ToLower(strings.Join(strings.Fields(name), " ")))
+}
+
+// OrgRollup totals the resources of an organization across IPTs. Name is its
+// canonical name, or else its most used spelling, Spellings every one seen,
+// and Share its fraction of the occurrences of all organizations.
+type OrgRollup struct {
+	Name              string
+	Spellings         []string
+	IPTs              []string
+	Resources         int
+	Occurrences       int
+	Events            int
+	Measurements      int
+	LatestPublication time.Time
+	Share             float64
+}
+
+// NotRegistered is the organization IPTs show for resources not registered
+// with any organization.
+const NotRegistered = "Not registered"
+
+// Rollup groups the resources of every IPT by organization, using n to tell
+// which names are the same organization. Resources without an organization,
+// or NotRegistered, are left out, neither ranked nor counted in shares.
+// Rollups are sorted by occurrences.
+func Rollup(ipts []IPT, n *OrgNormalizer) []OrgRollup {
+	orgs := map[string]*OrgRollup{}
+	spellings := map[string]map[string]int{}
+	order := []string{}
+	total := 0
+	unregistered := n.Key(NotRegistered)
+
+	for _, ipt := range ipts {
+		for _, r := range ipt.Resources {
+			k := n.Key(r.Organization)
+			if k == "" || k == unregistered {
+				continue
+			}
+			o, ok := orgs[k]
+			if !ok {
+				o = &OrgRollup{}
+				orgs[k] = o
+				spellings[k] = map[string]int{}
+				order = append(order, k)
+			}
+			if spellings[k][r.Organization] == 0 {
+				o.Spellings = append(o.Spellings, r.Organization)
+			}
+			spellings[k][r.Organization]++
+			if last := len(o.IPTs) - 1; last < 0 || o.IPTs[last] != ipt.Name {
+				o.IPTs = append(o.IPTs, ipt.Name)
+			}
+
+			o.Resources++
+			o.Occurrences += r.Occurrences
+			o.Events += r.Events
+			o.Measurements += r.Measurements
+			if r.LastPublication.After(o.LatestPublication) {
+				o.LatestPublication = r.LastPublication
+			}
+			total += r.Occurrences
+		}
+	}
+
+	rollups := make([]OrgRollup, 0, len(orgs))
+	for _, k := range order {
+		o := orgs[k]
+		for _, s := range o.Spellings {
+			if spellings[k][s] > spellings[k][o.Name] {
+				o.Name = s
+			}
+		}
+		if o.Name == "" {
+			o.Name = o.Spellings[0]
+		}
+		if canonical, ok := n.Canonical(o.Name); ok {
+			o.Name = canonical
+		}
+		if total > 0 {
+			o.Share = float64(o.Occurrences) / float64(total)
+		}
+		rollups = append(rollups, *o)
+	}
+	SortRollups(rollups, "occurrences")
+
+	return rollups
+}
+
+// RollupOrders are the orders SortRollups knows. Totals and dates sort in
+// descending order, names in ascending order.
+var RollupOrders = []string{"occurrences", "resources", "events", "measurements", "latest", "name"}
+
+// SortRollups sorts rollups as a leaderboard by one of RollupOrders, breaking
+// ties by name.
+func SortRollups(rollups []OrgRollup, by string) error {
+	var less func(a, b OrgRollup) bool
+	switch by {
+	case "occurrences":
+		less = func(a, b OrgRollup) bool { return a.Occurrences > b.Occurrences }
+	case "resources":
+		less = func(a, b OrgRollup) bool { return a.Resources > b.Resources }
+	case "events":
+		less = func(a, b OrgRollup) bool { return a.Events > b.Events }
+	case "measurements":
+		less = func(a, b OrgRollup) bool { return a.Measurements > b.Measurements }
+	case "latest":
+		less = func(a, b OrgRollup) bool { return a.LatestPublication.After(b.LatestPublication) }
+	case "name":
+		less = func(a, b OrgRollup) bool { return false }
+	default:
+		return fmt.Errorf("Unknown order %s", by)
+	}
+
+	sort.SliceStable(rollups, func(i, j int) bool {
+		a, b := rollups[i], rollups[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Name < b.Name
+	})
+	return nil
+}
+
+// WriteRollupCSV writes the rollups in their order, ranked from 1.
+func WriteRollupCSV(w io.Writer, rollups []OrgRollup) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"Rank", "Organization", "IPTs", "Resources", "Occurrences", "Events", "Measurements", "LatestPublication", "Share", "Spellings"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for i, o := range rollups {
+		line := []string{
+			strconv.Itoa(i + 1),
+			o.Name,
+			strings.Join(o.IPTs, "; "),
+			strconv.Itoa(o.Resources),
+			strconv.Itoa(o.Occurrences),
+			strconv.Itoa(o.Events),
+			strconv.Itoa(o.Measurements),
+			formatDate(o.LatestPublication),
+			strconv.FormatFloat(o.Share*100, 'f', 2, 64) + "%",
+			strings.Join(o.Spellings, "; "),
+		}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,86 @@
+package iptReport
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeOrganization(t *testing.T) {
+	tableCases := []struct {
+		input  string
+		output string
+	}{
+		{"Instituto Nacional de Pesquisas da Amazônia", "instituto nacional de pesquisas da amazonia"},
+		{"  instituto  nacional de pesquisas da Amazônia.", "instituto nacional de pesquisas da amazonia"},
+		{"(SiBBr)", "sibbr"},
+		{"", ""},
+	}
+
+	for _, tt := range tableCases {
+		if got := NormalizeOrganization(tt.input); got != tt.output {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.output)
+		}
+	}
+}
+
+func TestRollup(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2018, 1, d, 0, 0, 0, 0, time.UTC) }
+	ipts := []IPT{
+		{Name: "a", Resources: []Resource{
+			{Organization: "SiBBr", Occurrences: 10, Events: 1, LastPublication: day(3)},
+			{Organization: "sibbr ", Occurrences: 20, LastPublication: day(1)},
+			{Organization: "INPA", Occurrences: 50, Measurements: 4, LastPublication: day(2)},
+		}},
+		{Name: "b", Resources: []Resource{
+			{Organization: "SiBBr", Occurrences: 20, LastPublication: day(5)},
+			{Organization: "Not registered", Occurrences: 500},
+			{Occurrences: 30},
+		}},
+		{Name: "c", Err: fmt.Errorf("unreachable")},
+	}
+
+	rollups := Rollup(ipts, NewOrgNormalizer())
+	want := []OrgRollup{
+		{Name: "INPA", Spellings: []string{"INPA"}, IPTs: []string{"a"}, Resources: 1, Occurrences: 50, Measurements: 4, LatestPublication: day(2), Share: 0.5},
+		{Name: "SiBBr", Spellings: []string{"SiBBr", "sibbr "}, IPTs: []string{"a", "b"}, Resources: 3, Occurrences: 50, Events: 1, LatestPublication: day(5), Share: 0.5},
+	}
+	if !reflect.DeepEqual(rollups, want) {
+		t.Errorf("got \n%#v, want \n%#v", rollups, want)
+	}
+
+	tableCases := []struct {
+		input       string
+		output      string
+		shouldError bool
+	}{
+		{"resources", "SiBBr", false},
+		{"latest", "SiBBr", false},
+		{"measurements", "INPA", false},
+		{"name", "INPA", false},
+		{"size", "", true},
+	}
+
+	for _, tt := range tableCases {
+		err := SortRollups(rollups, tt.input)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error sorting by %s", tt.input)
+		} else if tt.shouldError == false && rollups[0].Name != tt.output {
+			t.Errorf("sorting by %s: got %s first, want %s", tt.input, rollups[0].Name, tt.output)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteRollupCSV(buf, rollups[:1]); err != nil {
+		t.Fatal(err)
+	}
+	want2 := "Rank,Organization,IPTs,Resources,Occurrences,Events,Measurements,LatestPublication,Share,Spellings\n" +
+		"1,INPA,a,1,50,0,4,2018-01-02,50.00%,INPA\n"
+	if buf.String() != want2 {
+		t.Errorf("got %q, want %q", buf.String(), want2)
+	}
+}
@@ -0,0 +1,284 @@
+package iptReport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/zieckey/goini"
+)
+
+// foldTable maps accented latin letters to their base letter.
+var foldTable = map[rune]rune{}
+
+func init() {
+	for base, accented := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'd': "ďđ", 'e': "èéêëēĕėęě",
+		'g': "ĝğġģ", 'h': "ĥħ", 'i': "ìíîïĩīĭįı", 'j': "ĵ", 'k': "ķ",
+		'l': "ĺļľŀł", 'n': "ñńņňŉ", 'o': "òóôõöøōŏő", 'r': "ŕŗř",
+		's': "śŝşšș", 't': "ţťŧț", 'u': "ùúûüũūŭůűų", 'w': "ŵ", 'y': "ýÿŷ", 'z': "źżž",
+	} {
+		for _, r := range accented {
+			foldTable[r] = base
+			foldTable[unicode.ToUpper(r)] = unicode.ToUpper(base)
+		}
+	}
+}
+
+// FoldAccents replaces accented latin letters of s by their base letter.
+func FoldAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if base, ok := foldTable[r]; ok {
+			return base
+		}
+		return r
+	}, s)
+}
+
+// MergeCandidate is a pair of organization names which are possibly the same
+// organization, by Reason, with a Similarity from 0 to 1.
+type MergeCandidate struct {
+	A, B       string
+	Reason     string
+	Similarity float64
+}
+
+// Reasons of merge candidates.
+const (
+	MergeSimilar = "similar spelling"
+	MergeAcronym = "acronym"
+)
+
+// OrgNormalizer tells which organization names are the same organization,
+// beyond NormalizeOrganization, from a curated alias mapping. Names not
+// mapped but at least Threshold similar or a single typo apart, or acronyms
+// of each other, are proposed by Candidates for review, never merged
+// automatically: distinct organizations often have similar names.
+type OrgNormalizer struct {
+	Threshold float64
+	canonical map[string]string
+}
+
+// NewOrgNormalizer returns a normalizer without aliases.
+func NewOrgNormalizer() *OrgNormalizer {
+	return &OrgNormalizer{Threshold: 0.85, canonical: map[string]string{}}
+}
+
+// ReadOrgAliases reads an ini file where each section is the canonical name
+// of an organization listing other names of it, separated by semicolons,
+// e.g.:
+//
+//	[Universidade Federal de Minas Gerais]
+//	aliases=UFMG; Univ. Federal de Minas Gerais
+func ReadOrgAliases(path string) (*OrgNormalizer, error) {
+	ini := goini.New()
+	if err := ini.ParseFile(path); err != nil {
+		return nil, err
+	}
+
+	n := NewOrgNormalizer()
+	for canonical, kv := range ini.GetAll() {
+		if canonical == "" {
+			continue
+		}
+		n.AddAlias(canonical, canonical)
+		for _, alias := range strings.Split(kv["aliases"], ";") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				n.AddAlias(alias, canonical)
+			}
+		}
+	}
+	return n, nil
+}
+
+// AddAlias maps alias, and its trivially different spellings, to canonical.
+func (n *OrgNormalizer) AddAlias(alias, canonical string) {
+	n.canonical[NormalizeOrganization(alias)] = canonical
+}
+
+// Canonical returns the canonical name of an aliased organization.
+func (n *OrgNormalizer) Canonical(name string) (string, bool) {
+	canonical, ok := n.canonical[NormalizeOrganization(name)]
+	return canonical, ok
+}
+
+// Key returns the key grouping every name of the organization.
+func (n *OrgNormalizer) Key(name string) string {
+	if canonical, ok := n.Canonical(name); ok {
+		return NormalizeOrganization(canonical)
+	}
+	return NormalizeOrganization(name)
+}
+
+// orgStopWords are skipped building acronyms.
+var orgStopWords = map[string]bool{
+	"de": true, "da": true, "do": true, "das": true, "dos": true, "e": true,
+	"del": true, "la": true, "y": true, "of": true, "the": true, "and": true, "for": true,
+}
+
+// acronym returns the initials of the words of key other than stop words.
+func acronym(key string) string {
+	initials := []rune{}
+	for _, word := range orgWords(key) {
+		if !orgStopWords[word] {
+			initials = append(initials, []rune(word)[0])
+		}
+	}
+	return string(initials)
+}
+
+// orgBoilerplate are words common to the names of many distinct
+// organizations, which tell them apart only when just one name has them.
+var orgBoilerplate = map[string]bool{
+	"universidade": true, "universidad": true, "university": true, "faculdade": true,
+	"federal": true, "estadual": true, "instituto": true, "institute": true, "nacional": true,
+	"museu": true, "museum": true, "fundacao": true, "centro": true,
+}
+
+// orgWords splits a key into words.
+func orgWords(key string) []string {
+	return strings.FieldsFunc(key, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// distinctive returns the words of keys a and b other than stop words and
+// the boilerplate words both have, so that e.g. "universidade federal do
+// para" and "universidade federal do parana" compare as "para" and
+// "parana".
+func distinctive(a, b string) (string, string) {
+	wordsA, wordsB := orgWords(a), orgWords(b)
+	set := func(words []string) map[string]bool {
+		in := map[string]bool{}
+		for _, word := range words {
+			in[word] = true
+		}
+		return in
+	}
+	keep := func(words []string, other map[string]bool) string {
+		kept := []string{}
+		for _, word := range words {
+			if !orgStopWords[word] && !(orgBoilerplate[word] && other[word]) {
+				kept = append(kept, word)
+			}
+		}
+		return strings.Join(kept, " ")
+	}
+	return keep(wordsA, set(wordsB)), keep(wordsB, set(wordsA))
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent letters turning a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(minInt(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// similarity returns 1 minus the edit distance of a and b over the length of
+// the longest.
+func similarity(a, b string) float64 {
+	longest := maxInt(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// typoLength is the length from which words a single edit apart are taken as
+// a typo whatever the threshold, as one edit weighs more in short words.
+const typoLength = 5
+
+// similar reports whether keys a and b are spelled alike, by their
+// distinctive words, and how much.
+func (n *OrgNormalizer) similar(a, b string) (float64, bool) {
+	a, b = distinctive(a, b)
+	s := similarity(a, b)
+	if s >= n.Threshold {
+		return s, true
+	}
+	shortest := minInt(len([]rune(a)), len([]rune(b)))
+	return s, shortest >= typoLength && editDistance(a, b) == 1
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Candidates proposes merges between names grouped under different keys,
+// most similar first. Names are compared by their distinctive words, and
+// single word names as acronyms of the others.
+func (n *OrgNormalizer) Candidates(names []string) []MergeCandidate {
+	keys := []string{}
+	spelling := map[string]string{}
+	for _, name := range names {
+		k := n.Key(name)
+		if _, ok := spelling[k]; ok || k == "" {
+			continue
+		}
+		spelling[k] = name
+		if canonical, ok := n.Canonical(name); ok {
+			spelling[k] = canonical
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	candidates := []MergeCandidate{}
+	for i, a := range keys {
+		for _, b := range keys[i+1:] {
+			if s, ok := n.similar(a, b); ok {
+				candidates = append(candidates, MergeCandidate{A: spelling[a], B: spelling[b], Reason: MergeSimilar, Similarity: s})
+				continue
+			}
+			short, long := a, b
+			if len(short) > len(long) {
+				short, long = long, short
+			}
+			if !strings.Contains(short, " ") && len(short) > 1 && acronym(long) == short {
+				candidates = append(candidates, MergeCandidate{A: spelling[a], B: spelling[b], Reason: MergeAcronym, Similarity: 1})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Similarity > candidates[j].Similarity })
+
+	return candidates
+}
+
+// WriteMergeCandidates writes the candidates in the format of ReadOrgAliases,
+// commented out for review. The longest name of each pair is proposed as the
+// canonical one.
+func WriteMergeCandidates(w io.Writer, candidates []MergeCandidate) error {
+	buf := bufio.NewWriter(w)
+	for _, c := range candidates {
+		canonical, alias := c.A, c.B
+		if len([]rune(alias)) > len([]rune(canonical)) {
+			canonical, alias = alias, canonical
+		}
+		fmt.Fprintf(buf, "# %s, %.2f\n#[%s]\n#aliases=%s\n\n", c.Reason, c.Similarity, canonical, alias)
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,114 @@
+package iptReport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFoldAccents(t *testing.T) {
+	tableCases := []struct {
+		input  string
+		output string
+	}{
+		{"Instituto Nacional de Pesquisas da Amazônia", "Instituto Nacional de Pesquisas da Amazonia"},
+		{"FUNDAÇÃO ÁGUA", "FUNDACAO AGUA"},
+		{"São Paulo, Paraná, Piauí", "Sao Paulo, Parana, Piaui"},
+		{"Zoológico 動物園", "Zoologico 動物園"},
+	}
+
+	for _, tt := range tableCases {
+		if got := FoldAccents(tt.input); got != tt.output {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.output)
+		}
+	}
+}
+
+func TestReadOrgAliases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orgaliases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "aliases.ini")
+	ioutil.WriteFile(path, []byte("[Universidade Federal de Minas Gerais]\naliases=UFMG; Univ. Federal de Minas Gerais\n"), 0644)
+
+	n, err := ReadOrgAliases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadOrgAliases(filepath.Join(dir, "missing.ini")); err == nil {
+		t.Errorf("expected error reading a missing file")
+	}
+
+	tableCases := []struct {
+		input  string
+		output string
+	}{
+		{"UFMG", "universidade federal de minas gerais"},
+		{"ufmg.", "universidade federal de minas gerais"},
+		{"Univ. Federal de Minas Gerais", "universidade federal de minas gerais"},
+		{"UNIVERSIDADE FEDERAL DE MINAS GERAIS", "universidade federal de minas gerais"},
+		{"UFRJ", "ufrj"},
+	}
+
+	for _, tt := range tableCases {
+		if got := n.Key(tt.input); got != tt.output {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.output)
+		}
+	}
+
+	ipts := []IPT{{Name: "a", Resources: []Resource{
+		{Organization: "UFMG", Occurrences: 2},
+		{Organization: "UFMG", Occurrences: 2},
+		{Organization: "Universidade Federal de Minas Gerais", Occurrences: 1},
+	}}}
+	rollups := Rollup(ipts, n)
+	if len(rollups) != 1 || rollups[0].Name != "Universidade Federal de Minas Gerais" || rollups[0].Occurrences != 5 {
+		t.Errorf("got %#v", rollups)
+	}
+}
+
+func TestOrgCandidates(t *testing.T) {
+	n := NewOrgNormalizer()
+	n.AddAlias("SiBBr", "Sistema de Informação sobre a Biodiversidade Brasileira")
+	names := []string{
+		"Universidade Federal de Minas Gerais",
+		"Universidade Federal de Minas Gerias",
+		"Universidade Estadual de Goiás",
+		"Universidade Estadual de Goisa",
+		"UFMG",
+		"INPA",
+		"Instituto Nacional de Pesquisas da Amazônia",
+		"SiBBr",
+		"Sistema de Informacao sobre a Biodiversidade Brasileira",
+		"Museu Nacional",
+		"Universidade Federal do Pará",
+		"Universidade Federal do Paraná",
+		"Universidade Federal de Alagoas",
+		"Universidade Federal de Goiás",
+		"Instituto Federal de Goiás",
+	}
+
+	want := []MergeCandidate{
+		{A: "INPA", B: "Instituto Nacional de Pesquisas da Amazônia", Reason: MergeAcronym, Similarity: 1},
+		{A: "UFMG", B: "Universidade Federal de Minas Gerais", Reason: MergeAcronym, Similarity: 1},
+		{A: "UFMG", B: "Universidade Federal de Minas Gerias", Reason: MergeAcronym, Similarity: 1},
+		{A: "Universidade Federal de Minas Gerais", B: "Universidade Federal de Minas Gerias", Reason: MergeSimilar, Similarity: 1 - 1.0/12},
+		{A: "Universidade Estadual de Goiás", B: "Universidade Estadual de Goisa", Reason: MergeSimilar, Similarity: 1 - 1.0/5},
+	}
+	if got := n.Candidates(names); !reflect.DeepEqual(got, want) {
+		t.Errorf("got \n%#v, want \n%#v", got, want)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteMergeCandidates(buf, want[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if w := "# acronym, 1.00\n#[Instituto Nacional de Pesquisas da Amazônia]\n#aliases=INPA\n\n"; buf.String() != w {
+		t.Errorf("got %q, want %q", buf.String(), w)
+	}
+}
@@ -0,0 +1,266 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Names of the tallies of records falling outside every region and of records
+// without valid coordinates.
+const (
+	OutsideRegions = "(outside)"
+	NoCoordinates  = "(no coordinates)"
+)
+
+// Region is a named area made of one or more polygons, each a list of rings
+// of [longitude, latitude] points where the first ring is the outer
+// boundary and the others are holes.
+type Region struct {
+	Name     string
+	Polygons [][][][2]float64
+	bounded  bool
+	min, max [2]float64
+}
+
+type geoFeatureCollection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// ReadRegions reads every Polygon and MultiPolygon feature of the GeoJSON
+// FeatureCollection at path, naming regions after the property called
+// property.
+func ReadRegions(path, property string) ([]Region, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fc := geoFeatureCollection{}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, err
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("No FeatureCollection found at %s", path)
+	}
+
+	regions := []Region{}
+	for i, feature := range fc.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+		region := Region{Name: fmt.Sprintf("feature %d", i)}
+		if name, ok := feature.Properties[property]; ok && name != nil {
+			region.Name = fmt.Sprint(name)
+		}
+
+		switch feature.Geometry.Type {
+		case "Polygon":
+			polygon := [][][2]float64{}
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("%s: %v", region.Name, err)
+			}
+			region.Polygons = [][][][2]float64{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &region.Polygons); err != nil {
+				return nil, fmt.Errorf("%s: %v", region.Name, err)
+			}
+		default:
+			continue
+		}
+
+		regions = append(regions, region)
+	}
+
+	return regions, nil
+}
+
+// bounds computes the bounding box of the region outer rings.
+func (r *Region) bounds() {
+	r.bounded = true
+	r.min = [2]float64{math.Inf(1), math.Inf(1)}
+	r.max = [2]float64{math.Inf(-1), math.Inf(-1)}
+	for _, polygon := range r.Polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, p := range polygon[0] {
+			for i := range p {
+				r.min[i] = math.Min(r.min[i], p[i])
+				r.max[i] = math.Max(r.max[i], p[i])
+			}
+		}
+	}
+}
+
+// inRing tests whether the point is inside ring by ray casting.
+func inRing(ring [][2]float64, lon, lat float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) &&
+			lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Contains reports whether the point is inside any polygon of the region and
+// outside its holes.
+func (r *Region) Contains(lat, lon float64) bool {
+	if !r.bounded {
+		r.bounds()
+	}
+	if lon < r.min[0] || lon > r.max[0] || lat < r.min[1] || lat > r.max[1] {
+		return false
+	}
+	for _, polygon := range r.Polygons {
+		if len(polygon) == 0 || !inRing(polygon[0], lon, lat) {
+			continue
+		}
+		hole := false
+		for _, ring := range polygon[1:] {
+			if inRing(ring, lon, lat) {
+				hole = true
+				break
+			}
+		}
+		if !hole {
+			return true
+		}
+	}
+	return false
+}
+
+type regionKey struct {
+	region, ipt string
+}
+
+// RegionCount is the number of records and distinct scientific names found
+// at a region, for a single IPT or, when IPT is empty, for all of them.
+type RegionCount struct {
+	Region  string
+	IPT     string
+	Records int
+	Species int
+}
+
+// RegionTally assigns georeferenced occurrences to regions. Records lacking
+// valid coordinates are only counted at Unlocated, per IPT.
+type RegionTally struct {
+	Regions   []Region
+	Unlocated map[string]int
+	records   map[regionKey]int
+	species   map[regionKey]map[string]bool
+}
+
+// NewRegionTally returns an empty tally over regions.
+func NewRegionTally(regions []Region) *RegionTally {
+	return &RegionTally{
+		Regions:   regions,
+		Unlocated: map[string]int{},
+		records:   map[regionKey]int{},
+		species:   map[regionKey]map[string]bool{},
+	}
+}
+
+func (t *RegionTally) count(region, ipt, species string) {
+	for _, key := range []regionKey{{region, ipt}, {region, ""}} {
+		t.records[key]++
+		if species == "" {
+			continue
+		}
+		if t.species[key] == nil {
+			t.species[key] = map[string]bool{}
+		}
+		t.species[key][species] = true
+	}
+}
+
+// Add counts rec, published by ipt, at every region holding its coordinates
+// or at OutsideRegions.
+func (t *RegionTally) Add(ipt string, rec Record) {
+	lat, lon, ok := rec.Coordinates()
+	if !ok {
+		t.Unlocated[ipt]++
+		return
+	}
+
+	species := rec.Get("scientificName")
+	inside := false
+	for i := range t.Regions {
+		if t.Regions[i].Contains(lat, lon) {
+			t.count(t.Regions[i].Name, ipt, species)
+			inside = true
+		}
+	}
+	if !inside {
+		t.count(OutsideRegions, ipt, species)
+	}
+}
+
+// AddArchive adds every occurrence of the archive published by ipt.
+func (t *RegionTally) AddArchive(ipt string, a *Archive) error {
+	return a.EachOccurrence(func(rec Record) error {
+		t.Add(ipt, rec)
+		return nil
+	})
+}
+
+// Counts returns the tallies sorted by region and IPT, the total of each
+// region coming first with an empty IPT.
+func (t *RegionTally) Counts() []RegionCount {
+	counts := make([]RegionCount, 0, len(t.records))
+	for key, records := range t.records {
+		counts = append(counts, RegionCount{key.region, key.ipt, records, len(t.species[key])})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Region != counts[j].Region {
+			return counts[i].Region < counts[j].Region
+		}
+		return counts[i].IPT < counts[j].IPT
+	})
+	return counts
+}
+
+// WriteCSV writes the tallies, with region totals having an empty IPT, and
+// the unlocated records of each IPT.
+func (t *RegionTally) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	if err := out.Write([]string{"Region", "IPT", "Records", "Species"}); err != nil {
+		return err
+	}
+	for _, c := range t.Counts() {
+		if err := out.Write([]string{c.Region, c.IPT, strconv.Itoa(c.Records), strconv.Itoa(c.Species)}); err != nil {
+			return err
+		}
+	}
+
+	ipts := make([]string, 0, len(t.Unlocated))
+	for ipt := range t.Unlocated {
+		ipts = append(ipts, ipt)
+	}
+	sort.Strings(ipts)
+	for _, ipt := range ipts {
+		if err := out.Write([]string{NoCoordinates, ipt, strconv.Itoa(t.Unlocated[ipt]), ""}); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
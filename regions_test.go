@@ -0,0 +1,127 @@
+package iptReport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRegions has two squares, the second with a hole at its center, and a
+// line which must be ignored.
+const testRegions = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"name": "Norte"},
+     "geometry": {"type": "Polygon", "coordinates": [[[-70, -10], [-50, -10], [-50, 0], [-70, 0], [-70, -10]]]}},
+    {"type": "Feature", "properties": {"name": "Centro"},
+     "geometry": {"type": "MultiPolygon", "coordinates": [[
+       [[-50, -20], [-40, -20], [-40, -10], [-50, -10], [-50, -20]],
+       [[-48, -18], [-42, -18], [-42, -12], [-48, -12], [-48, -18]]
+     ]]}},
+    {"type": "Feature", "properties": {"name": "Rio"},
+     "geometry": {"type": "LineString", "coordinates": [[-60, -5], [-55, -5]]}}
+  ]
+}`
+
+func TestReadRegions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "regions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "regions.geojson")
+	if err := ioutil.WriteFile(path, []byte(testRegions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	regions, err := ReadRegions(path, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 || regions[0].Name != "Norte" || regions[1].Name != "Centro" {
+		t.Fatalf("got \n%#v", regions)
+	}
+
+	tableCases := []struct {
+		lat, lon float64
+		output   []bool
+	}{
+		{-5, -60, []bool{true, false}},
+		{-19, -49, []bool{false, true}},
+		{-15, -45, []bool{false, false}},
+		{-30, -60, []bool{false, false}},
+	}
+
+	for _, tt := range tableCases {
+		got := []bool{regions[0].Contains(tt.lat, tt.lon), regions[1].Contains(tt.lat, tt.lon)}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("%v,%v: got %v, want %v", tt.lat, tt.lon, got, tt.output)
+		}
+	}
+
+	if _, err := ReadRegions("THIS FILE SHOULDN'T EXIST", "name"); err == nil {
+		t.Error("expected error reading missing file")
+	}
+}
+
+func TestRegionTally(t *testing.T) {
+	dir, err := ioutil.TempDir("", "regions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "regions.geojson")
+	if err := ioutil.WriteFile(path, []byte(testRegions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	regions, err := ReadRegions(path, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := OpenArchive(writeArchive(t, dir, "occ.zip", testOccurrenceArchive(
+		"1\t\t\t\t\t\tAra ararauna\t-5\t-60\t\t",
+		"2\t\t\t\t\t\tAra ararauna\t-6\t-61\t\t",
+		"3\t\t\t\t\t\tPuma concolor\t-19\t-49\t\t",
+		"4\t\t\t\t\t\tPuma concolor\t-30\t-60\t\t",
+		"5\t\t\t\t\t\tPuma concolor\t\t\t\t",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	tally := NewRegionTally(regions)
+	if err := tally.AddArchive("sibbr", a); err != nil {
+		t.Fatal(err)
+	}
+	tally.Add("peld", Record{Terms: map[string]string{
+		"decimalLatitude": "-2", "decimalLongitude": "-52", "scientificName": "Puma concolor",
+	}})
+
+	want := []RegionCount{
+		{OutsideRegions, "", 1, 1},
+		{OutsideRegions, "sibbr", 1, 1},
+		{"Centro", "", 1, 1},
+		{"Centro", "sibbr", 1, 1},
+		{"Norte", "", 3, 2},
+		{"Norte", "peld", 1, 1},
+		{"Norte", "sibbr", 2, 1},
+	}
+	if got := tally.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got \n%#v, want \n%#v", got, want)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tally.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), NoCoordinates+",sibbr,1,\n") {
+		t.Errorf("got \n%s", buf.String())
+	}
+}
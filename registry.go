@@ -0,0 +1,409 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GBIFAPI is the address of the GBIF registry API.
+const GBIFAPI = "https://api.gbif.org/v1"
+
+// RegistryDataset is a dataset as known by a registry of biodiversity data.
+// Type is its registry type, e.g. OCCURRENCE or CHECKLIST at GBIF. Records is
+// the number of occurrences the registry indexed and LastCrawled when it last
+// finished harvesting the dataset, zero if never.
+type RegistryDataset struct {
+	Key                       string
+	Title                     string
+	Type                      string
+	InstallationKey           string
+	PublishingOrganizationKey string
+	Records                   int
+	LastCrawled               time.Time
+}
+
+// Installation is a publishing software installation, like an IPT, at a
+// registry.
+type Installation struct {
+	Key             string
+	Title           string
+	Type            string
+	OrganizationKey string
+	Endpoints       []string
+}
+
+// Registry is a registry of datasets, publishers and installations such as
+// GBIF. Dataset returns ErrNotRegistered for unknown keys.
+type Registry interface {
+	Dataset(key string) (*RegistryDataset, error)
+	PublishedDatasets(publisherKey string) ([]RegistryDataset, error)
+	Installation(key string) (*Installation, error)
+}
+
+// ErrNotRegistered is returned by a Registry for unknown keys.
+var ErrNotRegistered = fmt.Errorf("Not registered")
+
+// GBIFRegistry is a Registry using the GBIF registry REST API at BaseURL,
+// usually GBIFAPI.
+type GBIFRegistry struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewGBIFRegistry returns a client for the GBIF API at baseURL.
+func NewGBIFRegistry(baseURL string) *GBIFRegistry {
+	return &GBIFRegistry{BaseURL: strings.TrimRight(baseURL, "/"), Client: http.DefaultClient}
+}
+
+// get fetches path at the API decoding JSON into v, or the raw body into v
+// when it is a *[]byte.
+func (g *GBIFRegistry) get(path string, query url.Values, v interface{}) error {
+	u := g.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	resp, err := g.Client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotRegistered
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Fetching %s: %s", u, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if raw, ok := v.(*[]byte); ok {
+		*raw = body
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+type gbifDataset struct {
+	Key                       string `json:"key"`
+	Title                     string `json:"title"`
+	Type                      string `json:"type"`
+	InstallationKey           string `json:"installationKey"`
+	PublishingOrganizationKey string `json:"publishingOrganizationKey"`
+}
+
+func (d gbifDataset) dataset() RegistryDataset {
+	return RegistryDataset{
+		Key:                       d.Key,
+		Title:                     d.Title,
+		Type:                      d.Type,
+		InstallationKey:           d.InstallationKey,
+		PublishingOrganizationKey: d.PublishingOrganizationKey,
+	}
+}
+
+// Dataset fetches the dataset with its indexed occurrence count and last
+// finished crawl.
+func (g *GBIFRegistry) Dataset(key string) (*RegistryDataset, error) {
+	d := gbifDataset{}
+	if err := g.get("/dataset/"+key, nil, &d); err != nil {
+		return nil, err
+	}
+	dataset := d.dataset()
+
+	count := []byte{}
+	if err := g.get("/occurrence/count", url.Values{"datasetKey": {key}}, &count); err != nil {
+		return nil, err
+	}
+	records, err := strconv.Atoi(strings.TrimSpace(string(count)))
+	if err != nil {
+		return nil, err
+	}
+	dataset.Records = records
+
+	process := struct {
+		Results []struct {
+			FinishedCrawling string `json:"finishedCrawling"`
+		} `json:"results"`
+	}{}
+	if err := g.get("/dataset/"+key+"/process", url.Values{"limit": {"1"}}, &process); err != nil {
+		return nil, err
+	}
+	if len(process.Results) > 0 && process.Results[0].FinishedCrawling != "" {
+		if dataset.LastCrawled, err = parseGBIFTime(process.Results[0].FinishedCrawling); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dataset, nil
+}
+
+// parseGBIFTime parses the timestamps of the GBIF API, which may lack a zone.
+func parseGBIFTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000-0700", "2006-01-02T15:04:05.000", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown time format %q", s)
+}
+
+// paged fetches every page of a paged listing at path, handing the results
+// of each page to add, which returns how many there were.
+func (g *GBIFRegistry) paged(path string, query url.Values, add func(results json.RawMessage) (int, error)) error {
+	for offset := 0; ; {
+		q := url.Values{"limit": {"100"}, "offset": {strconv.Itoa(offset)}}
+		for k, v := range query {
+			q[k] = v
+		}
+		page := struct {
+			EndOfRecords bool            `json:"endOfRecords"`
+			Results      json.RawMessage `json:"results"`
+		}{}
+		if err := g.get(path, q, &page); err != nil {
+			return err
+		}
+		n, err := add(page.Results)
+		if err != nil {
+			return err
+		}
+		offset += n
+		if page.EndOfRecords || n == 0 {
+			return nil
+		}
+	}
+}
+
+// PublishedDatasets lists every dataset published by the organization,
+// without record counts or crawl dates.
+func (g *GBIFRegistry) PublishedDatasets(publisherKey string) ([]RegistryDataset, error) {
+	datasets := []RegistryDataset{}
+	err := g.paged("/organization/"+publisherKey+"/publishedDataset", nil, func(results json.RawMessage) (int, error) {
+		page := []gbifDataset{}
+		if err := json.Unmarshal(results, &page); err != nil {
+			return 0, err
+		}
+		for _, d := range page {
+			datasets = append(datasets, d.dataset())
+		}
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return datasets, nil
+}
+
+type gbifInstallation struct {
+	Key             string `json:"key"`
+	Title           string `json:"title"`
+	Type            string `json:"type"`
+	OrganizationKey string `json:"organizationKey"`
+	Endpoints       []struct {
+		URL string `json:"url"`
+	} `json:"endpoints"`
+}
+
+func (i gbifInstallation) installation() Installation {
+	installation := Installation{Key: i.Key, Title: i.Title, Type: i.Type, OrganizationKey: i.OrganizationKey}
+	for _, e := range i.Endpoints {
+		installation.Endpoints = append(installation.Endpoints, e.URL)
+	}
+	return installation
+}
+
+// Installation fetches the installation with its endpoint URLs.
+func (g *GBIFRegistry) Installation(key string) (*Installation, error) {
+	i := gbifInstallation{}
+	if err := g.get("/installation/"+key, nil, &i); err != nil {
+		return nil, err
+	}
+	installation := i.installation()
+	return &installation, nil
+}
+
+// Issues found comparing a resource with its registry dataset.
+const (
+	IssueNotIndexed    = "not indexed"
+	IssueOutdated      = "outdated index"
+	IssueCountMismatch = "record count mismatch"
+	IssueInstallation  = "installation mismatch"
+	IssueNotHosted     = "not hosted by any crawled IPT"
+)
+
+// RegistryCheck compares a Resource of the IPT aliased IPT with its registry
+// Dataset, nil when the registry failed with Err.
+type RegistryCheck struct {
+	IPT      string
+	Resource Resource
+	Dataset  *RegistryDataset
+	Issues   []string
+	Err      error
+}
+
+// RegistryReport holds a check for every registered resource and the
+// datasets of the same publishers no crawled IPT hosts.
+type RegistryReport struct {
+	Checks   []RegistryCheck
+	Unhosted []RegistryDataset
+	Errs     []error
+}
+
+// sameInstallation reports whether any endpoint of installation lives under
+// iptURL, ignoring the scheme. Installations without endpoints can't be told
+// apart from the IPT and are taken as the same one rather than reported.
+func sameInstallation(installation *Installation, iptURL string) bool {
+	strip := func(s string) string {
+		if i := strings.Index(s, "://"); i >= 0 {
+			s = s[i+3:]
+		}
+		return strings.TrimRight(s, "/")
+	}
+	base := strip(iptURL)
+	for _, endpoint := range installation.Endpoints {
+		e := strip(endpoint)
+		if e == base || strings.HasPrefix(e, base+"/") {
+			return true
+		}
+	}
+	return len(installation.Endpoints) == 0
+}
+
+// indexesOccurrences reports whether the occurrences of r are comparable
+// with the records the registry indexed of d: checklists count taxa and
+// metadata only resources nothing. The registry type is used when known,
+// or else the resource type.
+func indexesOccurrences(r Resource, d *RegistryDataset) bool {
+	kind := d.Type
+	if kind == "" {
+		kind = r.Type
+	}
+	kind = strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(kind))
+	return kind != "checklist" && kind != "metadata"
+}
+
+// CheckRegistry compares every resource having a DatasetKey with the
+// registry: datasets it doesn't know or, holding occurrences, has no records
+// of are not indexed, the ones last crawled before the last publication are
+// outdated, and record counts of occurrence and sampling event datasets must
+// match the IPT occurrences. It also lists datasets of the publishers seen
+// which no crawled resource refers to.
+func CheckRegistry(reg Registry, ipts []IPT) RegistryReport {
+	report := RegistryReport{}
+	hosted := map[string]bool{}
+	publishers := []string{}
+	seenPublisher := map[string]bool{}
+	installations := map[string]*Installation{}
+
+	for _, ipt := range ipts {
+		for _, r := range ipt.Resources {
+			if r.PublisherKey != "" && !seenPublisher[r.PublisherKey] {
+				seenPublisher[r.PublisherKey] = true
+				publishers = append(publishers, r.PublisherKey)
+			}
+			if r.DatasetKey == "" {
+				continue
+			}
+			hosted[r.DatasetKey] = true
+
+			check := RegistryCheck{IPT: ipt.Name, Resource: r}
+			check.Dataset, check.Err = reg.Dataset(r.DatasetKey)
+			if check.Err == ErrNotRegistered {
+				check.Err = nil
+				check.Issues = append(check.Issues, IssueNotIndexed)
+			}
+			if check.Dataset != nil {
+				d := check.Dataset
+				switch {
+				case !indexesOccurrences(r, d):
+				case d.Records == 0 && r.Occurrences > 0:
+					check.Issues = append(check.Issues, IssueNotIndexed)
+				case d.Records != r.Occurrences && r.Occurrences > 0:
+					check.Issues = append(check.Issues, IssueCountMismatch)
+				}
+				// by date, as the IPT lists publication dates without time
+				crawled := formatDate(d.LastCrawled.In(r.LastPublication.Location()))
+				if !r.LastPublication.IsZero() && crawled < formatDate(r.LastPublication) {
+					check.Issues = append(check.Issues, IssueOutdated)
+				}
+
+				if d.InstallationKey != "" && ipt.URL != "" {
+					installation, ok := installations[d.InstallationKey]
+					if !ok {
+						var err error
+						if installation, err = reg.Installation(d.InstallationKey); err != nil {
+							report.Errs = append(report.Errs, err)
+						}
+						installations[d.InstallationKey] = installation
+					}
+					if installation != nil && !sameInstallation(installation, ipt.URL) {
+						check.Issues = append(check.Issues, IssueInstallation)
+					}
+				}
+			}
+			report.Checks = append(report.Checks, check)
+		}
+	}
+
+	for _, publisher := range publishers {
+		datasets, err := reg.PublishedDatasets(publisher)
+		if err != nil {
+			report.Errs = append(report.Errs, fmt.Errorf("publisher %s: %v", publisher, err))
+			continue
+		}
+		for _, d := range datasets {
+			if !hosted[d.Key] {
+				report.Unhosted = append(report.Unhosted, d)
+			}
+		}
+	}
+
+	return report
+}
+
+// WriteCSV writes a line per checked resource followed by a line per unhosted
+// dataset. Resources without issues are only written when all is true.
+func (r RegistryReport) WriteCSV(w io.Writer, all bool) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"IPT", "Resource", "Dataset key", "Occurrences", "Indexed records", "Last publication", "Last crawled", "Issues", "Error"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+
+	for _, c := range r.Checks {
+		if len(c.Issues) == 0 && c.Err == nil && !all {
+			continue
+		}
+		line := []string{c.IPT, c.Resource.Name, c.Resource.DatasetKey, strconv.Itoa(c.Resource.Occurrences), "", formatDate(c.Resource.LastPublication), "", strings.Join(c.Issues, "; "), ""}
+		if c.Dataset != nil {
+			line[4], line[6] = strconv.Itoa(c.Dataset.Records), formatDate(c.Dataset.LastCrawled)
+		}
+		if c.Err != nil {
+			line[8] = c.Err.Error()
+		}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+	for _, d := range r.Unhosted {
+		line := []string{"", d.Title, d.Key, "", "", "", "", IssueNotHosted, ""}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,186 @@
+package iptReport
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testGBIF() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dataset/d1":
+			fmt.Fprint(w, `{"key":"d1","title":"Dataset one","type":"OCCURRENCE","installationKey":"i1","publishingOrganizationKey":"p1"}`)
+		case "/occurrence/count":
+			if r.URL.Query().Get("datasetKey") != "d1" {
+				fmt.Fprint(w, "0")
+				return
+			}
+			fmt.Fprint(w, "1500")
+		case "/dataset/d1/process":
+			fmt.Fprint(w, `{"offset":0,"limit":1,"endOfRecords":false,"results":[{"finishedCrawling":"2018-03-01T10:00:00.000+0000"}]}`)
+		case "/installation/i1":
+			fmt.Fprint(w, `{"key":"i1","title":"IPT","type":"IPT_INSTALLATION","organizationKey":"p1","endpoints":[{"url":"https://ipt.example.org/ipt/rss.do"}]}`)
+		case "/organization/p1/publishedDataset":
+			if r.URL.Query().Get("offset") == "0" {
+				fmt.Fprint(w, `{"endOfRecords":false,"results":[{"key":"d1","title":"Dataset one"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"endOfRecords":true,"results":[{"key":"d2","title":"Dataset two"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestGBIFRegistry(t *testing.T) {
+	ts := testGBIF()
+	defer ts.Close()
+	reg := NewGBIFRegistry(ts.URL + "/")
+
+	d, err := reg.Dataset("d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RegistryDataset{Key: "d1", Title: "Dataset one", Type: "OCCURRENCE", InstallationKey: "i1", PublishingOrganizationKey: "p1",
+		Records: 1500, LastCrawled: time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)}
+	if !d.LastCrawled.Equal(want.LastCrawled) {
+		t.Errorf("got crawled %v, want %v", d.LastCrawled, want.LastCrawled)
+	}
+	d.LastCrawled = want.LastCrawled
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got \n%#v, want \n%#v", d, want)
+	}
+
+	if _, err := reg.Dataset("missing"); err != ErrNotRegistered {
+		t.Errorf("got %v for a missing dataset, want ErrNotRegistered", err)
+	}
+
+	i, err := reg.Installation("i1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(i.Endpoints, []string{"https://ipt.example.org/ipt/rss.do"}) {
+		t.Errorf("got endpoints %v", i.Endpoints)
+	}
+
+	datasets, err := reg.PublishedDatasets("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(datasets) != 2 || datasets[0].Key != "d1" || datasets[1].Key != "d2" {
+		t.Errorf("got published datasets %#v", datasets)
+	}
+}
+
+type stubRegistry struct {
+	datasets      map[string]RegistryDataset
+	installations map[string]Installation
+	published     map[string][]RegistryDataset
+}
+
+func (s stubRegistry) Dataset(key string) (*RegistryDataset, error) {
+	d, ok := s.datasets[key]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return &d, nil
+}
+
+func (s stubRegistry) Installation(key string) (*Installation, error) {
+	i, ok := s.installations[key]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return &i, nil
+}
+
+func (s stubRegistry) PublishedDatasets(publisherKey string) ([]RegistryDataset, error) {
+	return s.published[publisherKey], nil
+}
+
+func TestCheckRegistry(t *testing.T) {
+	published := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	reg := stubRegistry{
+		datasets: map[string]RegistryDataset{
+			"ok":       {Key: "ok", InstallationKey: "i1", Records: 10, LastCrawled: published.AddDate(0, 0, 1)},
+			"outdated": {Key: "outdated", InstallationKey: "i1", Records: 8, LastCrawled: published.AddDate(0, 0, -1)},
+			"empty":    {Key: "empty", InstallationKey: "i1", LastCrawled: published.AddDate(0, 0, 1)},
+			"moved":    {Key: "moved", InstallationKey: "i2", Records: 10, LastCrawled: published.AddDate(0, 0, 1)},
+			"taxa":     {Key: "taxa", InstallationKey: "i1", LastCrawled: published.AddDate(0, 0, 1)},
+			"meta":     {Key: "meta", Type: "METADATA", InstallationKey: "i1", LastCrawled: published.AddDate(0, 0, 1)},
+			"events":   {Key: "events", Type: "SAMPLING_EVENT", InstallationKey: "i1", Records: 3, LastCrawled: published.AddDate(0, 0, 1)},
+			"sameday":  {Key: "sameday", InstallationKey: "i1", Records: 10, LastCrawled: published.Add(9 * time.Hour)},
+		},
+		installations: map[string]Installation{
+			"i1": {Key: "i1", Endpoints: []string{"http://ipt.example.org/ipt/rss.do"}},
+			"i2": {Key: "i2", Endpoints: []string{"https://other.example.org/ipt/rss.do"}},
+		},
+		published: map[string][]RegistryDataset{
+			"p1": {{Key: "ok"}, {Key: "outdated"}, {Key: "elsewhere", Title: "Elsewhere"}},
+		},
+	}
+	resource := func(key string, occurrences int) Resource {
+		return Resource{Name: key, DatasetKey: key, PublisherKey: "p1", Occurrences: occurrences, LastPublication: published}
+	}
+	ipts := []IPT{{Name: "example", URL: "https://ipt.example.org/ipt/", Resources: []Resource{
+		resource("ok", 10),
+		resource("outdated", 10),
+		resource("empty", 10),
+		resource("moved", 10),
+		resource("unknown", 10),
+		{Name: "taxa", DatasetKey: "taxa", Type: "Checklist", Occurrences: 120, LastPublication: published},
+		resource("meta", 2),
+		resource("events", 10),
+		{Name: "sameday", DatasetKey: "sameday", PublisherKey: "p1", Occurrences: 10, LastPublication: published.Add(15 * time.Hour)},
+		{Name: "unregistered", Occurrences: 5},
+	}}}
+
+	tableCases := []struct {
+		input  string
+		output []string
+	}{
+		{"ok", nil},
+		{"outdated", []string{IssueCountMismatch, IssueOutdated}},
+		{"empty", []string{IssueNotIndexed}},
+		{"moved", []string{IssueInstallation}},
+		{"unknown", []string{IssueNotIndexed}},
+		{"taxa", nil},
+		{"meta", nil},
+		{"events", []string{IssueCountMismatch}},
+		{"sameday", nil},
+	}
+
+	report := CheckRegistry(reg, ipts)
+	if len(report.Checks) != len(tableCases) {
+		t.Fatalf("got %d checks, want %d", len(report.Checks), len(tableCases))
+	}
+	for i, tt := range tableCases {
+		c := report.Checks[i]
+		if c.Resource.DatasetKey != tt.input || c.Err != nil {
+			t.Errorf("check %d: got %s (%v), want %s", i, c.Resource.DatasetKey, c.Err, tt.input)
+		} else if !reflect.DeepEqual(c.Issues, tt.output) {
+			t.Errorf("%s: got issues %v, want %v", tt.input, c.Issues, tt.output)
+		}
+	}
+	if len(report.Unhosted) != 1 || report.Unhosted[0].Key != "elsewhere" {
+		t.Errorf("got unhosted %#v", report.Unhosted)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := report.WriteCSV(buf, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 7 {
+		t.Errorf("got %d csv lines, want 7:\n%s", len(lines), buf)
+	}
+	if want := ",Elsewhere,elsewhere,,,,," + IssueNotHosted + ","; lines[len(lines)-1] != want {
+		t.Errorf("got %q, want %q", lines[len(lines)-1], want)
+	}
+}
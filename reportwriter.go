@@ -0,0 +1,292 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportWriter writes a report of crawled IPTs in some format. WriteReport
+// calls Begin once, WriteIPT for each IPT followed by WriteResource for each
+// of its resources, and End once.
+type ReportWriter interface {
+	Begin() error
+	WriteIPT(ipt IPT) error
+	WriteResource(ipt IPT, r Resource) error
+	End() error
+}
+
+// WriteReport writes ipts with rw.
+func WriteReport(rw ReportWriter, ipts []IPT) error {
+	if err := rw.Begin(); err != nil {
+		return err
+	}
+	for _, ipt := range ipts {
+		if err := rw.WriteIPT(ipt); err != nil {
+			return err
+		}
+		for _, r := range ipt.Resources {
+			if err := rw.WriteResource(ipt, r); err != nil {
+				return err
+			}
+		}
+	}
+	return rw.End()
+}
+
+// NewReportWriter returns the writer of format: csv, tsv, json, ndjson,
+// markdown or xlsx. JSON reports are stamped as generated at generated.
+func NewReportWriter(w io.Writer, format string, generated time.Time) (ReportWriter, error) {
+	switch format {
+	case "csv":
+		return NewCSVWriter(w), nil
+	case "tsv":
+		return NewTSVWriter(w), nil
+	case "json":
+		return NewJSONWriter(w, generated), nil
+	case "ndjson":
+		return NewNDJSONWriter(w), nil
+	case "markdown":
+		return NewMarkdownWriter(w), nil
+	case "xlsx":
+		return NewXLSXWriter(w), nil
+	}
+	return nil, fmt.Errorf("Unknown report format %s", format)
+}
+
+// reportTitles are the columns of CSV and TSV reports.
+var reportTitles = []string{
+	"IPT",
+	"Resource Name",
+	"Link",
+	"Logo",
+	"Organization",
+	"Type",
+	"Subtype",
+	"Events",
+	"Measurements",
+	"Occurrences",
+	"LastModified",
+	"LastPublication",
+	"NextPublication",
+	"Visibility",
+	"Author",
+	"Error",
+}
+
+// DelimitedWriter writes a line per resource, and a line with the error of
+// each IPT which failed, as comma or tab separated values.
+type DelimitedWriter struct {
+	out *csv.Writer
+}
+
+// NewCSVWriter returns a writer of comma separated values.
+func NewCSVWriter(w io.Writer) *DelimitedWriter {
+	return &DelimitedWriter{out: csv.NewWriter(w)}
+}
+
+// NewTSVWriter returns a writer of tab separated values.
+func NewTSVWriter(w io.Writer) *DelimitedWriter {
+	out := csv.NewWriter(w)
+	out.Comma = '\t'
+	return &DelimitedWriter{out: out}
+}
+
+// Begin writes the titles.
+func (d *DelimitedWriter) Begin() error {
+	return d.out.Write(reportTitles)
+}
+
+// WriteIPT writes a line with the error of a failed IPT.
+func (d *DelimitedWriter) WriteIPT(ipt IPT) error {
+	if ipt.Err == nil {
+		return nil
+	}
+	line := make([]string, len(reportTitles))
+	line[0], line[len(line)-1] = ipt.Name, ipt.Err.Error()
+	return d.out.Write(line)
+}
+
+// WriteResource writes a line per resource. Dates are written as Go prints
+// them, the next publication being empty when unscheduled.
+func (d *DelimitedWriter) WriteResource(ipt IPT, r Resource) error {
+	next := ""
+	if !r.NextPublication.IsZero() {
+		next = r.NextPublication.String()
+	}
+	return d.out.Write([]string{ipt.Name, r.Name, r.Link, r.Logo, r.Organization, r.Type, r.Subtype,
+		strconv.Itoa(r.Events), strconv.Itoa(r.Measurements), strconv.Itoa(r.Occurrences),
+		r.LastModified.String(), r.LastPublication.String(), next, r.Visibility, r.Author, ""})
+}
+
+// End flushes the lines written.
+func (d *DelimitedWriter) End() error {
+	d.out.Flush()
+	return d.out.Error()
+}
+
+// JSONWriter writes a single indented JSONReport, see JSONSchema. It keeps
+// the whole report until End.
+type JSONWriter struct {
+	w      io.Writer
+	report JSONReport
+}
+
+// NewJSONWriter returns a writer of a JSON report generated at generated.
+func NewJSONWriter(w io.Writer, generated time.Time) *JSONWriter {
+	return &JSONWriter{w: w, report: NewJSONReport(nil, generated)}
+}
+
+// Begin starts an empty report.
+func (j *JSONWriter) Begin() error {
+	j.report.IPTs = []JSONIPT{}
+	return nil
+}
+
+// WriteIPT adds ipt to the report, without its resources.
+func (j *JSONWriter) WriteIPT(ipt IPT) error {
+	ipt.Resources = nil
+	j.report.IPTs = append(j.report.IPTs, NewJSONIPT(ipt))
+	return nil
+}
+
+// WriteResource adds r to the last IPT written.
+func (j *JSONWriter) WriteResource(ipt IPT, r Resource) error {
+	if len(j.report.IPTs) == 0 {
+		return fmt.Errorf("Resource %s written before its IPT %s", r.Name, ipt.Name)
+	}
+	last := &j.report.IPTs[len(j.report.IPTs)-1]
+	last.Resources = append(last.Resources, NewJSONResource(r))
+	return nil
+}
+
+// End writes the report.
+func (j *JSONWriter) End() error {
+	data, err := json.MarshalIndent(j.report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+// NDJSONWriter writes a JSONIPT per line, with its resources, as each IPT is
+// complete.
+type NDJSONWriter struct {
+	buf     *bufio.Writer
+	enc     *json.Encoder
+	current *JSONIPT
+}
+
+// NewNDJSONWriter returns a writer of newline delimited json.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	buf := bufio.NewWriter(w)
+	return &NDJSONWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Begin does nothing, NDJSON having no header.
+func (n *NDJSONWriter) Begin() error {
+	return nil
+}
+
+// flush writes the current IPT, if any.
+func (n *NDJSONWriter) flush() error {
+	if n.current == nil {
+		return nil
+	}
+	err := n.enc.Encode(n.current)
+	n.current = nil
+	return err
+}
+
+// WriteIPT writes the previous IPT and starts ipt, without its resources.
+func (n *NDJSONWriter) WriteIPT(ipt IPT) error {
+	if err := n.flush(); err != nil {
+		return err
+	}
+	ipt.Resources = nil
+	current := NewJSONIPT(ipt)
+	n.current = &current
+	return nil
+}
+
+// WriteResource adds r to the current IPT.
+func (n *NDJSONWriter) WriteResource(ipt IPT, r Resource) error {
+	if n.current == nil {
+		return fmt.Errorf("Resource %s written before its IPT %s", r.Name, ipt.Name)
+	}
+	n.current.Resources = append(n.current.Resources, NewJSONResource(r))
+	return nil
+}
+
+// End writes the last IPT.
+func (n *NDJSONWriter) End() error {
+	if err := n.flush(); err != nil {
+		return err
+	}
+	return n.buf.Flush()
+}
+
+// MarkdownWriter writes a section per IPT with a table of its resources.
+type MarkdownWriter struct {
+	buf *bufio.Writer
+}
+
+// NewMarkdownWriter returns a writer of markdown.
+func NewMarkdownWriter(w io.Writer) *MarkdownWriter {
+	return &MarkdownWriter{buf: bufio.NewWriter(w)}
+}
+
+// markdownCell escapes pipes and line breaks of a table cell.
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Begin writes the title.
+func (m *MarkdownWriter) Begin() error {
+	_, err := fmt.Fprintln(m.buf, "# IPT report")
+	return err
+}
+
+// WriteIPT writes the heading of ipt and its error, or the header of its
+// resources table.
+func (m *MarkdownWriter) WriteIPT(ipt IPT) error {
+	fmt.Fprintf(m.buf, "\n## %s\n\n", ipt.Name)
+	if ipt.URL != "" {
+		fmt.Fprintf(m.buf, "<%s>\n\n", ipt.URL)
+	}
+	if ipt.Err != nil {
+		fmt.Fprintf(m.buf, "**Error:** %s\n", markdownCell(ipt.Err.Error()))
+		return nil
+	}
+	if len(ipt.Resources) == 0 {
+		fmt.Fprintln(m.buf, "No resources.")
+		return nil
+	}
+	fmt.Fprintln(m.buf, "| Resource | Organization | Type | Occurrences | Events | Measurements | Last publication | Visibility |")
+	_, err := fmt.Fprintln(m.buf, "|---|---|---|--:|--:|--:|---|---|")
+	return err
+}
+
+// WriteResource writes a table row, linking the resource name.
+func (m *MarkdownWriter) WriteResource(ipt IPT, r Resource) error {
+	name := markdownCell(r.Name)
+	if r.Link != "" {
+		name = fmt.Sprintf("[%s](%s)", name, r.Link)
+	}
+	_, err := fmt.Fprintf(m.buf, "| %s | %s | %s | %d | %d | %d | %s | %s |\n", name, markdownCell(r.Organization),
+		markdownCell(typeName(r)), r.Occurrences, r.Events, r.Measurements, formatDate(r.LastPublication),
+		markdownCell(r.Visibility))
+	return err
+}
+
+// End flushes the report.
+func (m *MarkdownWriter) End() error {
+	return m.buf.Flush()
+}
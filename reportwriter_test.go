@@ -0,0 +1,101 @@
+package iptReport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportWriters(t *testing.T) {
+	generated := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	published := time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC)
+	ipts := []IPT{
+		{Name: "a", URL: "http://ipt.example.org", Resources: []Resource{
+			{Name: "Birds | Aves", Link: "http://ipt.example.org/resource?r=birds", Organization: "INPA", Type: "Occurrence",
+				Occurrences: 10, LastModified: published, LastPublication: published, Visibility: "Public", Author: "Ana"},
+		}},
+		{Name: "b", URL: "http://down.example.org", Err: errors.New("connection refused")},
+	}
+
+	tableCases := []struct {
+		format      string
+		output      string
+		shouldError bool
+	}{
+		{"csv", `IPT,Resource Name,Link,Logo,Organization,Type,Subtype,Events,Measurements,Occurrences,LastModified,LastPublication,NextPublication,Visibility,Author,Error
+a,Birds | Aves,http://ipt.example.org/resource?r=birds,,INPA,Occurrence,,0,0,10,2018-05-02 00:00:00 +0000 UTC,2018-05-02 00:00:00 +0000 UTC,,Public,Ana,
+b,,,,,,,,,,,,,,,connection refused
+`, false},
+		{"tsv", "IPT\tResource Name\tLink\tLogo\tOrganization\tType\tSubtype\tEvents\tMeasurements\tOccurrences\tLastModified\tLastPublication\tNextPublication\tVisibility\tAuthor\tError\n" +
+			"a\tBirds | Aves\thttp://ipt.example.org/resource?r=birds\t\tINPA\tOccurrence\t\t0\t0\t10\t2018-05-02 00:00:00 +0000 UTC\t2018-05-02 00:00:00 +0000 UTC\t\tPublic\tAna\t\n" +
+			"b\t\t\t\t\t\t\t\t\t\t\t\t\t\t\tconnection refused\n", false},
+		{"markdown", `# IPT report
+
+## a
+
+<http://ipt.example.org>
+
+| Resource | Organization | Type | Occurrences | Events | Measurements | Last publication | Visibility |
+|---|---|---|--:|--:|--:|---|---|
+| [Birds \| Aves](http://ipt.example.org/resource?r=birds) | INPA | Occurrence | 10 | 0 | 0 | 2018-05-02 | Public |
+
+## b
+
+<http://down.example.org>
+
+**Error:** connection refused
+`, false},
+		{"xml", "", true},
+	}
+
+	for _, tt := range tableCases {
+		buf := &bytes.Buffer{}
+		rw, err := NewReportWriter(buf, tt.format, generated)
+		if (err != nil) != tt.shouldError {
+			t.Errorf("%s: got error %v", tt.format, err)
+			continue
+		}
+		if tt.shouldError {
+			continue
+		}
+		if err := WriteReport(rw, ipts); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.output {
+			t.Errorf("%s: got \n%s, want \n%s", tt.format, buf, tt.output)
+		}
+	}
+
+	// the json writers nest resources in their IPTs
+	buf := &bytes.Buffer{}
+	if err := WriteReport(NewJSONWriter(buf, generated), ipts); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		IPTs []struct {
+			Resources []struct{ Name string }
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.IPTs) != 2 || len(doc.IPTs[0].Resources) != 1 || len(doc.IPTs[1].Resources) != 0 {
+		t.Errorf("got %+v", doc)
+	}
+
+	buf.Reset()
+	ndjson := NewNDJSONWriter(buf)
+	if err := ndjson.WriteResource(ipts[0], ipts[0].Resources[0]); err == nil {
+		t.Error("expected error for a resource written before its IPT")
+	}
+	if err := WriteReport(ndjson, ipts); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"name":"Birds | Aves"`) {
+		t.Errorf("got \n%s", buf)
+	}
+}
@@ -0,0 +1,255 @@
+package iptReport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// FeedItem is an entry of the IPT RSS feed of recently updated resources.
+type FeedItem struct {
+	Title     string
+	Link      string
+	Published time.Time
+}
+
+// Shortname returns the r parameter of the item link.
+func (i FeedItem) Shortname() string {
+	return Resource{Link: i.Link}.Shortname()
+}
+
+// Feed is the RSS feed an IPT serves at rss.do.
+type Feed struct {
+	Title string
+	Items []FeedItem
+}
+
+type rss struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			GUID    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// FeedURL returns the address of the RSS feed of the IPT at iptURL.
+func FeedURL(iptURL string) string {
+	return strings.TrimRight(iptURL, "/") + "/rss.do"
+}
+
+// parsePubDate parses the RFC 822 dates of RSS, with or without the day of the
+// week and numeric zones.
+func parsePubDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	layouts := []string{time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822,
+		"2 Jan 2006 15:04:05 -0700", "2 Jan 2006 15:04:05 MST"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown date format %q", s)
+}
+
+// FetchFeed reads the RSS feed at url.
+func FetchFeed(url string) (*Feed, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching %s: %s", url, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := rss{}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{Title: doc.Channel.Title}
+	for _, item := range doc.Channel.Items {
+		fi := FeedItem{Title: item.Title, Link: strings.TrimSpace(item.Link)}
+		if fi.Link == "" {
+			fi.Link = strings.TrimSpace(item.GUID)
+		}
+		if fi.Published, err = parsePubDate(item.PubDate); err != nil {
+			return nil, err
+		}
+		feed.Items = append(feed.Items, fi)
+	}
+
+	return feed, nil
+}
+
+// UpdatedSince returns the shortnames of resources published after t.
+func (f *Feed) UpdatedSince(t time.Time) map[string]bool {
+	updated := map[string]bool{}
+	for _, item := range f.Items {
+		if item.Published.After(t) {
+			updated[item.Shortname()] = true
+		}
+	}
+	return updated
+}
+
+// CrawlState keeps what an incremental crawl needs from previous runs: when
+// they happened and the resources bound for each IPT alias.
+type CrawlState struct {
+	LastRun  time.Time
+	LastFull time.Time
+	IPTs     map[string][]Resource
+}
+
+// ReadCrawlState reads the state saved at path. A missing file is an empty
+// state, which makes the next crawl a full one.
+func ReadCrawlState(path string) (*CrawlState, error) {
+	state := &CrawlState{IPTs: map[string][]Resource{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.IPTs == nil {
+		state.IPTs = map[string][]Resource{}
+	}
+
+	return state, nil
+}
+
+// Write saves the state at path.
+func (s *CrawlState) Write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// FullDue reports whether the last full crawl is older than interval.
+func (s *CrawlState) FullDue(interval time.Duration, now time.Time) bool {
+	return s.LastFull.IsZero() || now.Sub(s.LastFull) >= interval
+}
+
+// Update stores the resources of every IPT crawled without error. IPTs
+// failing keep the resources of their last successful crawl. Resources with
+// a BindError are left out, so their counts aren't reused and the next
+// incremental crawl visits them again.
+func (s *CrawlState) Update(ipts []IPT, full bool, now time.Time) {
+	for _, ipt := range ipts {
+		if ipt.Err != nil {
+			continue
+		}
+		failed := map[string]bool{}
+		for _, err := range ipt.BindErrs {
+			if bindErr, ok := err.(*BindError); ok {
+				failed[bindErr.Resource] = true
+			}
+		}
+		resources := []Resource{}
+		for _, r := range ipt.Resources {
+			if !failed[r.Name] {
+				resources = append(resources, r)
+			}
+		}
+		s.IPTs[ipt.Name] = resources
+	}
+	s.LastRun = now
+	if full {
+		s.LastFull = now
+	}
+}
+
+// bindIncremental binds the rows of result, only crawling resource pages of
+// resources at updated, new ones or ones whose records column changed. The
+// others get their counts from previous.
+func bindIncremental(result IPTResult, iptURL string, previous []Resource, updated map[string]bool) IPT {
+	ipt := IPT{Name: result.Name, URL: iptURL, Version: result.Version, Unchanged: map[string]bool{}}
+	if result.Err != nil {
+		ipt.Err = result.Err
+		return ipt
+	}
+
+	known := map[ResourceKey]Resource{}
+	for _, r := range previous {
+		known[r.Key()] = r
+	}
+
+	for _, resource := range result.Msg {
+		col := Resource{}
+		detailed, err := col.BindRow(resource)
+		if err == nil && detailed {
+			old, ok := known[col.Key()]
+			if ok && !updated[col.Shortname()] && old.Records == col.Records {
+				col.Occurrences, col.Events, col.Measurements = old.Occurrences, old.Events, old.Measurements
+				col.DatasetKey, col.PublisherKey = old.DatasetKey, old.PublisherKey
+				ipt.Unchanged[col.Shortname()] = true
+			} else {
+				err = col.CrawlResource()
+			}
+		}
+		if err != nil {
+			ipt.BindErrs = append(ipt.BindErrs, &BindError{Resource: col.Name, Err: err})
+		}
+		ipt.Resources = append(ipt.Resources, col)
+	}
+
+	return ipt
+}
+
+// CrawlIncremental crawls every IPT at ipts, a map of alias to url, like
+// Crawl, but only visits resource pages of resources the IPT RSS feed lists
+// as updated since state.LastRun. IPTs without a previous crawl or a readable
+// feed are fully crawled. Resources reused from state are marked at
+// IPT.Unchanged.
+func CrawlIncremental(ipts map[string]string, state *CrawlState) []IPT {
+	return crawlAll(ipts, func(r IPTResult, iptURL string) IPT {
+		previous, ok := state.IPTs[r.Name]
+		if !ok || r.Err != nil {
+			return NewIPT(r, iptURL)
+		}
+
+		feed, err := FetchFeed(FeedURL(iptURL))
+		if err != nil {
+			return NewIPT(r, iptURL)
+		}
+		return bindIncremental(r, iptURL, previous, feed.UpdatedSince(state.LastRun))
+	})
+}
+
+// Crawl crawls every IPT at ipts, incrementally unless the last full crawl is
+// older than fullEvery, and updates the state with the results.
+func (s *CrawlState) Crawl(ipts map[string]string, fullEvery time.Duration, now time.Time) []IPT {
+	full := s.FullDue(fullEvery, now)
+
+	var IPTs []IPT
+	if full {
+		IPTs = Crawl(ipts)
+	} else {
+		IPTs = CrawlIncremental(ipts, s)
+	}
+
+	s.Update(IPTs, full, now)
+	return IPTs
+}
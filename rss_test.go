@@ -0,0 +1,158 @@
+package iptReport
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchFeed(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rss.do" {
+			http.NotFound(w, r)
+			return
+		}
+		file, _ := os.Open("testdata/rss/feed.xml")
+		defer file.Close()
+		io.Copy(w, file)
+	}))
+	defer feed.Close()
+
+	tableCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{FeedURL(feed.URL + "/"), false},
+		{feed.URL + "/missing.do", true},
+		{"THIS URL SHOULDN'T EXIST", true},
+	}
+
+	for _, tt := range tableCases {
+		f, err := FetchFeed(tt.input)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error fetching %s", tt.input)
+		}
+		if err != nil {
+			continue
+		}
+
+		if len(f.Items) != 2 {
+			t.Fatalf("got \n%#v", f)
+		}
+		published := time.Date(2017, time.November, 10, 16, 21, 3, 0, time.UTC)
+		if !f.Items[0].Published.Equal(published) {
+			t.Errorf("got %v, want %v", f.Items[0].Published, published)
+		}
+
+		updated := f.UpdatedSince(time.Date(2017, time.September, 1, 0, 0, 0, 0, time.UTC))
+		want := map[string]bool{"diversidade_de_macroinvertebrados_bentonicos_peld": true}
+		if !reflect.DeepEqual(updated, want) {
+			t.Errorf("got %v, want %v", updated, want)
+		}
+	}
+}
+
+func TestCrawlState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	state, err := ReadCrawlState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2018, time.July, 1, 0, 0, 0, 0, time.UTC)
+	if !state.FullDue(24*time.Hour, now) {
+		t.Error("expected full crawl without previous state")
+	}
+
+	state.Update([]IPT{
+		{Name: "peld", Resources: []Resource{{Name: "PELD", Records: 474}, {Name: "Broken", Records: 10}},
+			BindErrs: []error{&BindError{Resource: "Broken", Err: io.EOF}}},
+		{Name: "down", Err: io.EOF},
+	}, true, now)
+	if want := []Resource{{Name: "PELD", Records: 474}}; !reflect.DeepEqual(state.IPTs["peld"], want) {
+		t.Errorf("got \n%#v, want \n%#v", state.IPTs["peld"], want)
+	}
+	if err := state.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadCrawlState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, state) {
+		t.Errorf("got \n%#v, want \n%#v", read, state)
+	}
+	if read.FullDue(24*time.Hour, now.Add(time.Hour)) {
+		t.Error("unexpected full crawl one hour after the last one")
+	}
+}
+
+func TestBindIncremental(t *testing.T) {
+	crawled := 0
+	success := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		crawled++
+		file, _ := os.Open("testdata/resource/success.html")
+		defer file.Close()
+		io.Copy(w, file)
+	}))
+	defer success.Close()
+
+	row := func(shortname, records string) []string {
+		return []string{
+			"--",
+			"<a href=\"" + success.URL + "/resource?r=" + shortname + "\"><if>" + shortname + "</a>",
+			"Not registered",
+			"Samplingevent",
+			"--",
+			"<a href=\"" + success.URL + "/resource?r=" + shortname + "\">" + records + "</a>",
+			"2017-11-10",
+			"2017-11-10",
+			"--",
+			"--",
+			"Public",
+		}
+	}
+	previous := []Resource{
+		{Link: success.URL + "/resource?r=same", Records: 474, Occurrences: 1, Events: 2, Measurements: 3},
+		{Link: success.URL + "/resource?r=feed", Records: 474, Occurrences: 1, Events: 2, Measurements: 3},
+		{Link: success.URL + "/resource?r=grown", Records: 10, Occurrences: 1, Events: 2, Measurements: 3},
+	}
+	result := IPTResult{
+		Name: "peld",
+		Msg:  [][]string{row("same", "474"), row("feed", "474"), row("grown", "474"), row("new", "474")},
+	}
+
+	ipt := bindIncremental(result, success.URL, previous, map[string]bool{"feed": true})
+	if len(ipt.BindErrs) > 0 {
+		t.Fatal(ipt.BindErrs)
+	}
+	if crawled != 3 {
+		t.Errorf("crawled %d resource pages, want 3", crawled)
+	}
+	if want := map[string]bool{"same": true}; !reflect.DeepEqual(ipt.Unchanged, want) {
+		t.Errorf("got %v, want %v", ipt.Unchanged, want)
+	}
+
+	counts := [][3]int{}
+	for _, r := range ipt.Resources {
+		counts = append(counts, [3]int{r.Occurrences, r.Events, r.Measurements})
+	}
+	want := [][3]int{{1, 2, 3}, {4019, 474, 4019}, {4019, 474, 4019}, {4019, 474, 4019}}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("got %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,89 @@
+package iptReport
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// instanceURL returns the address of the IPT instance serving link, a
+// resource page or an IPT action like logo.do, or "" for other links.
+func instanceURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	i := strings.LastIndex(u.Path, "/")
+	if i < 0 {
+		return ""
+	}
+	page := u.Path[i+1:]
+	if page != "resource" && !strings.HasSuffix(page, ".do") {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + u.Path[:i]
+}
+
+// SiblingCandidates returns the addresses of IPT instances on the host of ipt
+// which its resource links and logos point to, other than ipt itself.
+func SiblingCandidates(ipt IPT) []string {
+	base, err := url.Parse(ipt.URL)
+	if err != nil {
+		return nil
+	}
+	self := normalizeURL(ipt.URL)
+
+	seen := map[string]bool{}
+	candidates := []string{}
+	for _, r := range ipt.Resources {
+		for _, link := range []string{r.Link, r.Logo} {
+			instance := instanceURL(link)
+			if instance == "" || seen[normalizeURL(instance)] || normalizeURL(instance) == self {
+				continue
+			}
+			if u, _ := url.Parse(instance); !strings.EqualFold(u.Hostname(), base.Hostname()) {
+				continue
+			}
+			seen[normalizeURL(instance)] = true
+			candidates = append(candidates, instance)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// FindSiblings crawls the sibling candidates of every crawled IPT missing
+// from known, a map of alias to url, and returns the ones answering as IPTs,
+// with aliases unique among known, sorted by alias.
+func FindSiblings(crawled []IPT, known map[string]string) []IPT {
+	listed := map[string]bool{}
+	aliases := map[string]bool{}
+	for alias, u := range known {
+		listed[normalizeURL(u)] = true
+		aliases[alias] = true
+	}
+
+	siblings := []IPT{}
+	for _, ipt := range crawled {
+		for _, candidate := range SiblingCandidates(ipt) {
+			if listed[normalizeURL(candidate)] {
+				continue
+			}
+			listed[normalizeURL(candidate)] = true
+
+			alias := aliasFor(candidate)
+			for n := 2; aliases[alias]; n++ {
+				alias = fmt.Sprintf("%s-%d", aliasFor(candidate), n)
+			}
+			sibling := crawlOne(alias, candidate, NewIPT)
+			if sibling.Err != nil {
+				continue
+			}
+			aliases[alias] = true
+			siblings = append(siblings, sibling)
+		}
+	}
+	SortIPTs(siblings)
+	return siblings
+}
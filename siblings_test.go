@@ -0,0 +1,66 @@
+package iptReport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSiblingCandidates(t *testing.T) {
+	ipt := IPT{URL: "https://ipt.sibbr.gov.br/peld/", Resources: []Resource{
+		{Link: "https://ipt.sibbr.gov.br/peld/resource?r=own", Logo: "https://ipt.sibbr.gov.br/repatriados/logo.do?r=a"},
+		{Link: "https://ipt.sibbr.gov.br/repatriados/resource?r=a"},
+		{Link: "https://IPT.sibbr.gov.br/sibbr/resource.do?r=b", Logo: "https://ipt.sibbr.gov.br/images/logo.png"},
+		{Link: "https://ipt.other.org/peld/resource?r=c"},
+	}}
+
+	want := []string{"https://IPT.sibbr.gov.br/sibbr", "https://ipt.sibbr.gov.br/repatriados"}
+	if got := SiblingCandidates(ipt); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFindSiblings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/repatriados") {
+			http.NotFound(w, r)
+			return
+		}
+		file, _ := os.Open("testdata/home/index.html")
+		defer file.Close()
+		io.Copy(w, file)
+	}))
+	defer ts.Close()
+
+	crawled := []IPT{{Name: "peld", URL: ts.URL + "/peld", Resources: []Resource{
+		{Link: ts.URL + "/peld/resource?r=own"},
+		{Link: ts.URL + "/repatriados/resource?r=a", Logo: ts.URL + "/broken/logo.do?r=a"},
+	}}}
+
+	tableCases := []struct {
+		input  map[string]string
+		output map[string]string
+	}{
+		{map[string]string{"peld": ts.URL + "/peld"}, map[string]string{"repatriados": ts.URL + "/repatriados"}},
+		{map[string]string{"peld": ts.URL + "/peld", "repatriados": "https://elsewhere.org"}, map[string]string{"repatriados-2": ts.URL + "/repatriados"}},
+		{map[string]string{"peld": ts.URL + "/peld", "rep": ts.URL + "/repatriados/"}, map[string]string{}},
+	}
+
+	for _, tt := range tableCases {
+		siblings := FindSiblings(crawled, tt.input)
+		got := map[string]string{}
+		for _, s := range siblings {
+			got[s.Name] = s.URL
+			if len(s.Resources) == 0 {
+				t.Errorf("%s: sibling crawled without resources", s.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("got %v, want %v", got, tt.output)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package iptReport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotLayout names snapshot files after the UTC time they were taken.
+const snapshotLayout = "20060102T150405Z"
+
+// IPTSnapshot is an IPT as saved at a Snapshot, with its errors as text.
+type IPTSnapshot struct {
+	Name      string
+	URL       string
+	Version   string
+	Err       string
+	BindErrs  []string
+	Elapsed   time.Duration
+	Resources []Resource
+}
+
+// Healthy reports whether the IPT home page was crawled.
+func (s IPTSnapshot) Healthy() bool {
+	return s.Err == ""
+}
+
+// Snapshot is the result of a crawl run taken at Taken which lasted Elapsed.
+type Snapshot struct {
+	Taken   time.Time
+	Elapsed time.Duration
+	IPTs    []IPTSnapshot
+}
+
+// NewSnapshot records crawled IPTs as a snapshot taken at taken.
+func NewSnapshot(ipts []IPT, taken time.Time, elapsed time.Duration) *Snapshot {
+	s := &Snapshot{Taken: taken.UTC(), Elapsed: elapsed}
+	for _, ipt := range ipts {
+		is := IPTSnapshot{Name: ipt.Name, URL: ipt.URL, Version: ipt.Version, Elapsed: ipt.Elapsed, Resources: ipt.Resources}
+		if ipt.Err != nil {
+			is.Err = ipt.Err.Error()
+		}
+		for _, err := range ipt.BindErrs {
+			is.BindErrs = append(is.BindErrs, err.Error())
+		}
+		s.IPTs = append(s.IPTs, is)
+	}
+	return s
+}
+
+// ID returns the name the snapshot is stored under.
+func (s *Snapshot) ID() string {
+	return s.Taken.UTC().Format(snapshotLayout)
+}
+
+// IPT returns the IPT with alias name, or nil.
+func (s *Snapshot) IPT(name string) *IPTSnapshot {
+	for i := range s.IPTs {
+		if s.IPTs[i].Name == name {
+			return &s.IPTs[i]
+		}
+	}
+	return nil
+}
+
+// SnapshotInfo describes a stored snapshot without loading it.
+type SnapshotInfo struct {
+	ID    string
+	Taken time.Time
+	Size  int64
+}
+
+// SnapshotStore keeps snapshots as JSON files at Dir, one per crawl run.
+type SnapshotStore struct {
+	Dir string
+}
+
+// OpenSnapshotStore returns the store at dir, creating the directory when
+// missing.
+func OpenSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{Dir: dir}, nil
+}
+
+func (st *SnapshotStore) path(id string) string {
+	return filepath.Join(st.Dir, id+".json")
+}
+
+// Save stores s, failing if a snapshot taken at the same second exists. The
+// snapshot is written to a temporary file first, so a failed write never
+// leaves a partial one in the store.
+func (st *SnapshotStore) Save(s *Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(st.Dir, ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	// linking, unlike renaming, fails when the snapshot exists
+	err = os.Link(tmp.Name(), st.path(s.ID()))
+	if os.IsExist(err) {
+		return fmt.Errorf("Snapshot %s already exists", s.ID())
+	}
+	return err
+}
+
+// List returns the stored snapshots, oldest first.
+func (st *SnapshotStore) List() ([]SnapshotInfo, error) {
+	files, err := ioutil.ReadDir(st.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []SnapshotInfo{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		id := strings.TrimSuffix(f.Name(), ".json")
+		taken, err := time.Parse(snapshotLayout, id)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, SnapshotInfo{ID: id, Taken: taken, Size: f.Size()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Taken.Before(infos[j].Taken) })
+
+	return infos, nil
+}
+
+// Load reads the snapshot stored as id.
+func (st *SnapshotStore) Load(id string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(st.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No snapshot %s at %s", id, st.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Snapshot %s: %v", id, err)
+	}
+	return s, nil
+}
+
+// At loads the latest snapshot taken at or before t.
+func (st *SnapshotStore) At(t time.Time) (*Snapshot, error) {
+	infos, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		if !infos[i].Taken.After(t) {
+			return st.Load(infos[i].ID)
+		}
+	}
+	return nil, fmt.Errorf("No snapshot at %s taken before %s", st.Dir, t.Format(time.RFC3339))
+}
+
+// Between loads the snapshots taken from from to to, both inclusive, oldest
+// first. Zero times leave the range open.
+func (st *SnapshotStore) Between(from, to time.Time) ([]*Snapshot, error) {
+	infos, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*Snapshot{}
+	for _, info := range infos {
+		if !from.IsZero() && info.Taken.Before(from) || !to.IsZero() && info.Taken.After(to) {
+			continue
+		}
+		s, err := st.Load(info.ID)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+// Latest loads the most recent snapshot.
+func (st *SnapshotStore) Latest() (*Snapshot, error) {
+	infos, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("No snapshot at %s", st.Dir)
+	}
+	return st.Load(infos[len(infos)-1].ID)
+}
+
+// Prune removes the snapshots taken before cutoff, always keeping the keep
+// most recent ones, and returns the IDs removed.
+func (st *SnapshotStore) Prune(cutoff time.Time, keep int) ([]string, error) {
+	infos, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	for i, info := range infos {
+		if len(infos)-i <= keep || !info.Taken.Before(cutoff) {
+			break
+		}
+		if err := os.Remove(st.path(info.ID)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, info.ID)
+	}
+	return removed, nil
+}
@@ -0,0 +1,110 @@
+package iptReport
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := OpenSnapshotStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Latest(); err == nil {
+		t.Errorf("expected error loading from an empty store")
+	}
+
+	ipts := []IPT{
+		{Name: "peld", URL: "https://ipt.sibbr.gov.br/peld", Elapsed: time.Second, Resources: []Resource{
+			{Name: "A", Link: "https://ipt.sibbr.gov.br/peld/resource?r=a", Occurrences: 10,
+				LastPublication: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)},
+		}, BindErrs: []error{&BindError{Resource: "B", Err: fmt.Errorf("bad row")}}},
+		{Name: "down", URL: "https://down.org", Err: fmt.Errorf("No json found at https://down.org")},
+	}
+	day := func(d int) time.Time { return time.Date(2018, 3, d, 12, 0, 0, 0, time.UTC) }
+	for _, d := range []int{1, 8, 15} {
+		if err := st.Save(NewSnapshot(ipts, day(d), time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Save(NewSnapshot(ipts, day(8), time.Minute)); err == nil {
+		t.Errorf("expected error saving a snapshot twice")
+	}
+	if files, _ := ioutil.ReadDir(st.Dir); len(files) != 3 {
+		t.Errorf("got %d files, want the 3 snapshots only", len(files))
+	}
+	ioutil.WriteFile(filepath.Join(st.Dir, "notes.txt"), []byte("ignored"), 0644)
+
+	infos, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, info := range infos {
+		ids = append(ids, info.ID)
+	}
+	if want := []string{"20180301T120000Z", "20180308T120000Z", "20180315T120000Z"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+
+	s, err := st.Load("20180308T120000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Snapshot{Taken: day(8), Elapsed: time.Minute, IPTs: []IPTSnapshot{
+		{Name: "peld", URL: "https://ipt.sibbr.gov.br/peld", Elapsed: time.Second, Resources: ipts[0].Resources, BindErrs: []string{"B: bad row"}},
+		{Name: "down", URL: "https://down.org", Err: "No json found at https://down.org"},
+	}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got \n%#v, want \n%#v", s, want)
+	}
+	if s.IPT("down").Healthy() || !s.IPT("peld").Healthy() || s.IPT("missing") != nil {
+		t.Errorf("wrong IPT lookup or health")
+	}
+
+	tableCases := []struct {
+		input       time.Time
+		output      string
+		shouldError bool
+	}{
+		{day(10), "20180308T120000Z", false},
+		{day(15), "20180315T120000Z", false},
+		{day(1).Add(-time.Second), "", true},
+	}
+
+	for _, tt := range tableCases {
+		s, err := st.At(tt.input)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error loading at %s", tt.input)
+		} else if tt.shouldError == false && s.ID() != tt.output {
+			t.Errorf("at %s: got %s, want %s", tt.input, s.ID(), tt.output)
+		}
+	}
+	if _, err := st.Load("20170101T000000Z"); err == nil {
+		t.Errorf("expected error loading a missing snapshot")
+	}
+
+	removed, err := st.Prune(day(20), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"20180301T120000Z"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("pruned %v, want %v", removed, want)
+	}
+	if latest, err := st.Latest(); err != nil || latest.ID() != "20180315T120000Z" {
+		t.Errorf("got latest %v, %v", latest, err)
+	}
+}
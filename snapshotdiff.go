@@ -0,0 +1,276 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kinds of changes between snapshots.
+const (
+	ChangeAdded      = "added"
+	ChangeRemoved    = "removed"
+	ChangeCount      = "count"
+	ChangeVisibility = "visibility"
+	ChangeType       = "type"
+	ChangePublished  = "published"
+	ChangeFailing    = "failing"
+	ChangeRecovered  = "recovered"
+)
+
+// SnapshotChange is a change of an IPT, or of its resource when Resource,
+// the shortname, is set, between two snapshots. Field names the count of
+// ChangeCount changes, whose Delta is New minus Old.
+type SnapshotChange struct {
+	IPT      string      `json:"ipt"`
+	Resource string      `json:"resource,omitempty"`
+	Key      ResourceKey `json:"key,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	Kind     string      `json:"kind"`
+	Field    string      `json:"field,omitempty"`
+	Old      string      `json:"old,omitempty"`
+	New      string      `json:"new,omitempty"`
+	Delta    int         `json:"delta,omitempty"`
+}
+
+// SnapshotDiff holds the changes from the snapshot taken at From to the one
+// taken at To, in IPT order.
+type SnapshotDiff struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Changes []SnapshotChange `json:"changes"`
+}
+
+// resourceID identifies a resource within its IPT by its key, or else by its
+// link or name.
+func resourceID(r Resource) string {
+	if k := r.Key(); k != "" {
+		return string(k)
+	}
+	if r.Link != "" {
+		return r.Link
+	}
+	return r.Name
+}
+
+// resourceChange returns a change of kind of r, a resource of ipt.
+func resourceChange(ipt string, r Resource, kind string) SnapshotChange {
+	shortname := r.Shortname()
+	if shortname == "" {
+		shortname = resourceID(r)
+	}
+	return SnapshotChange{IPT: ipt, Resource: shortname, Key: r.Key(), Name: r.Name, Kind: kind}
+}
+
+// DiffSnapshots compares two snapshots. IPTs only in one of them are added or
+// removed, along with their resources. Resources of IPTs failing at either
+// one are not compared, only the IPT turning failing or recovering is, and
+// IPTs new and already failing are added and failing.
+func DiffSnapshots(from, to *Snapshot) SnapshotDiff {
+	d := SnapshotDiff{From: from.Taken, To: to.Taken, Changes: []SnapshotChange{}}
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, s := range []*Snapshot{from, to} {
+		for _, ipt := range s.IPTs {
+			if !seen[ipt.Name] {
+				seen[ipt.Name] = true
+				names = append(names, ipt.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		old, cur := from.IPT(name), to.IPT(name)
+		switch {
+		case old == nil:
+			d.Changes = append(d.Changes, SnapshotChange{IPT: name, Kind: ChangeAdded})
+		case cur == nil:
+			d.Changes = append(d.Changes, SnapshotChange{IPT: name, Kind: ChangeRemoved})
+		}
+		switch {
+		case old != nil && cur != nil && old.Healthy() && !cur.Healthy():
+			d.Changes = append(d.Changes, SnapshotChange{IPT: name, Kind: ChangeFailing, New: cur.Err})
+			continue
+		case old != nil && cur != nil && !old.Healthy() && cur.Healthy():
+			d.Changes = append(d.Changes, SnapshotChange{IPT: name, Kind: ChangeRecovered, Old: old.Err})
+		case old == nil && !cur.Healthy():
+			d.Changes = append(d.Changes, SnapshotChange{IPT: name, Kind: ChangeFailing, New: cur.Err})
+			continue
+		case old != nil && !old.Healthy() || cur != nil && !cur.Healthy():
+			continue
+		}
+
+		previous := map[string]Resource{}
+		if old != nil {
+			for _, r := range old.Resources {
+				previous[resourceID(r)] = r
+			}
+		}
+		current := map[string]bool{}
+		if cur != nil {
+			for _, r := range cur.Resources {
+				id := resourceID(r)
+				current[id] = true
+				p, ok := previous[id]
+				if !ok {
+					d.Changes = append(d.Changes, resourceChange(name, r, ChangeAdded))
+					continue
+				}
+				d.Changes = append(d.Changes, resourceChanges(name, p, r)...)
+			}
+		}
+		if old != nil {
+			for _, r := range old.Resources {
+				if !current[resourceID(r)] {
+					d.Changes = append(d.Changes, resourceChange(name, r, ChangeRemoved))
+				}
+			}
+		}
+	}
+
+	return d
+}
+
+// resourceChanges compares two versions of a resource of ipt. Records are
+// only compared when they count more than occurrences, as they do for other
+// cores than occurrence.
+func resourceChanges(ipt string, old, cur Resource) []SnapshotChange {
+	changes := []SnapshotChange{}
+	change := func(kind, field, o, n string, delta int) {
+		c := resourceChange(ipt, cur, kind)
+		c.Field, c.Old, c.New, c.Delta = field, o, n, delta
+		changes = append(changes, c)
+	}
+
+	counts := []struct {
+		field    string
+		old, cur int
+	}{
+		{"occurrences", old.Occurrences, cur.Occurrences},
+		{"events", old.Events, cur.Events},
+		{"measurements", old.Measurements, cur.Measurements},
+		{"records", old.Records, cur.Records},
+	}
+	sameRecords := old.Records == old.Occurrences && cur.Records == cur.Occurrences
+	for _, c := range counts {
+		if c.field == "records" && sameRecords {
+			continue
+		}
+		if c.old != c.cur {
+			change(ChangeCount, c.field, strconv.Itoa(c.old), strconv.Itoa(c.cur), c.cur-c.old)
+		}
+	}
+	if old.Visibility != cur.Visibility {
+		change(ChangeVisibility, "", old.Visibility, cur.Visibility, 0)
+	}
+	if old.Type != cur.Type || old.Subtype != cur.Subtype {
+		change(ChangeType, "", typeName(old), typeName(cur), 0)
+	}
+	if cur.LastPublication.After(old.LastPublication) {
+		change(ChangePublished, "", formatDate(old.LastPublication), formatDate(cur.LastPublication), 0)
+	}
+
+	return changes
+}
+
+func typeName(r Resource) string {
+	if r.Subtype == "" {
+		return r.Type
+	}
+	return r.Type + "/" + r.Subtype
+}
+
+// formatDate formats t as a date, empty when zero.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// Describe returns a line of text describing the change.
+func (c SnapshotChange) Describe() string {
+	resource := fmt.Sprintf("%s (%s)", c.Name, c.Resource)
+	if c.Resource == "" {
+		switch c.Kind {
+		case ChangeAdded:
+			return "+ new IPT"
+		case ChangeRemoved:
+			return "- IPT no longer listed"
+		}
+	}
+	switch c.Kind {
+	case ChangeAdded:
+		return "+ " + resource
+	case ChangeRemoved:
+		return "- " + resource
+	case ChangeCount:
+		return fmt.Sprintf("~ %s: %s %s -> %s (%+d)", resource, c.Field, c.Old, c.New, c.Delta)
+	case ChangeVisibility, ChangeType:
+		return fmt.Sprintf("~ %s: %s %s -> %s", resource, c.Kind, c.Old, c.New)
+	case ChangePublished:
+		if c.Old == "" {
+			return fmt.Sprintf("* %s: first published %s", resource, c.New)
+		}
+		return fmt.Sprintf("* %s: published %s, previously %s", resource, c.New, c.Old)
+	case ChangeFailing:
+		return "! failing: " + c.New
+	case ChangeRecovered:
+		return "! recovered, was failing: " + c.Old
+	}
+	return c.Kind
+}
+
+// WriteText writes the changes as plain text grouped by IPT.
+func (d SnapshotDiff) WriteText(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "Changes from %s to %s\n", d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04"))
+	if len(d.Changes) == 0 {
+		fmt.Fprintln(buf, "\nNo changes.")
+	}
+	for i, c := range d.Changes {
+		if i == 0 || d.Changes[i-1].IPT != c.IPT {
+			fmt.Fprintf(buf, "\n%s\n", c.IPT)
+		}
+		fmt.Fprintf(buf, "  %s\n", c.Describe())
+	}
+	return buf.Flush()
+}
+
+// WriteCSV writes a line per change.
+func (d SnapshotDiff) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	if err := out.Write([]string{"IPT", "Resource", "Key", "Name", "Change", "Field", "Old", "New", "Delta"}); err != nil {
+		return err
+	}
+	for _, c := range d.Changes {
+		delta := ""
+		if c.Kind == ChangeCount {
+			delta = strconv.Itoa(c.Delta)
+		}
+		if err := out.Write([]string{c.IPT, c.Resource, string(c.Key), c.Name, c.Kind, c.Field, c.Old, c.New, delta}); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteJSON writes the diff as an indented json object.
+func (d SnapshotDiff) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,122 @@
+package iptReport
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	link := func(r string) string { return "https://ipt.sibbr.gov.br/peld/resource?r=" + r }
+	day := func(d int) time.Time { return time.Date(2018, 3, d, 0, 0, 0, 0, time.UTC) }
+	from := &Snapshot{Taken: day(1), IPTs: []IPTSnapshot{
+		{Name: "peld", Resources: []Resource{
+			{Name: "Kept", Link: link("kept"), Occurrences: 10, Records: 10, Visibility: "Public", Type: "Occurrence", LastPublication: day(1)},
+			{Name: "Gone", Link: link("gone"), Occurrences: 5},
+			{Name: "Grown", Link: link("grown"), Occurrences: 3, Records: 3, Type: "Occurrence"},
+		}},
+		{Name: "retired", Resources: []Resource{{Name: "Old", Link: link("old")}}},
+		{Name: "down", Resources: []Resource{{Name: "Hidden", Link: link("hidden")}}},
+		{Name: "back", Err: "No json found"},
+		{Name: "renamed", Resources: []Resource{{Name: "Old title", Link: link("same")}}},
+	}}
+	to := &Snapshot{Taken: day(8), IPTs: []IPTSnapshot{
+		{Name: "peld", Resources: []Resource{
+			{Name: "Kept", Link: link("kept"), Occurrences: 25, Records: 12, Events: 2, Visibility: "Private", Type: "Samplingevent", LastPublication: day(7)},
+			{Name: "New", Link: link("new"), Occurrences: 1},
+			{Name: "Grown", Link: link("grown"), Occurrences: 4, Records: 4, Type: "Occurrence"},
+		}},
+		{Name: "opened", Resources: []Resource{{Name: "First", Link: link("first")}}},
+		{Name: "down", Err: "connection refused"},
+		{Name: "back", Resources: []Resource{{Name: "Back", Link: link("back")}}},
+		{Name: "renamed", Resources: []Resource{{Name: "New title", Link: link("same")}}},
+		{Name: "fresh", Err: "timeout"},
+	}}
+
+	d := DiffSnapshots(from, to)
+	want := []SnapshotChange{
+		{IPT: "back", Kind: ChangeRecovered, Old: "No json found"},
+		{IPT: "back", Resource: "back", Key: "ipt.sibbr.gov.br/peld?r=back", Name: "Back", Kind: ChangeAdded},
+		{IPT: "down", Kind: ChangeFailing, New: "connection refused"},
+		{IPT: "fresh", Kind: ChangeAdded},
+		{IPT: "fresh", Kind: ChangeFailing, New: "timeout"},
+		{IPT: "opened", Kind: ChangeAdded},
+		{IPT: "opened", Resource: "first", Key: "ipt.sibbr.gov.br/peld?r=first", Name: "First", Kind: ChangeAdded},
+		{IPT: "peld", Resource: "kept", Key: "ipt.sibbr.gov.br/peld?r=kept", Name: "Kept", Kind: ChangeCount, Field: "occurrences", Old: "10", New: "25", Delta: 15},
+		{IPT: "peld", Resource: "kept", Key: "ipt.sibbr.gov.br/peld?r=kept", Name: "Kept", Kind: ChangeCount, Field: "events", Old: "0", New: "2", Delta: 2},
+		{IPT: "peld", Resource: "kept", Key: "ipt.sibbr.gov.br/peld?r=kept", Name: "Kept", Kind: ChangeCount, Field: "records", Old: "10", New: "12", Delta: 2},
+		{IPT: "peld", Resource: "kept", Key: "ipt.sibbr.gov.br/peld?r=kept", Name: "Kept", Kind: ChangeVisibility, Old: "Public", New: "Private"},
+		{IPT: "peld", Resource: "kept", Key: "ipt.sibbr.gov.br/peld?r=kept", Name: "Kept", Kind: ChangeType, Old: "Occurrence", New: "Samplingevent"},
+		{IPT: "peld", Resource: "kept", Key: "ipt.sibbr.gov.br/peld?r=kept", Name: "Kept", Kind: ChangePublished, Old: "2018-03-01", New: "2018-03-07"},
+		{IPT: "peld", Resource: "new", Key: "ipt.sibbr.gov.br/peld?r=new", Name: "New", Kind: ChangeAdded},
+		{IPT: "peld", Resource: "grown", Key: "ipt.sibbr.gov.br/peld?r=grown", Name: "Grown", Kind: ChangeCount, Field: "occurrences", Old: "3", New: "4", Delta: 1},
+		{IPT: "peld", Resource: "gone", Key: "ipt.sibbr.gov.br/peld?r=gone", Name: "Gone", Kind: ChangeRemoved},
+		{IPT: "retired", Kind: ChangeRemoved},
+		{IPT: "retired", Resource: "old", Key: "ipt.sibbr.gov.br/peld?r=old", Name: "Old", Kind: ChangeRemoved},
+	}
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Errorf("got \n%#v, want \n%#v", d.Changes, want)
+	}
+
+	text := &bytes.Buffer{}
+	if err := d.WriteText(text); err != nil {
+		t.Fatal(err)
+	}
+	wantText := `Changes from 2018-03-01 00:00 to 2018-03-08 00:00
+
+back
+  ! recovered, was failing: No json found
+  + Back (back)
+
+down
+  ! failing: connection refused
+
+fresh
+  + new IPT
+  ! failing: timeout
+
+opened
+  + new IPT
+  + First (first)
+
+peld
+  ~ Kept (kept): occurrences 10 -> 25 (+15)
+  ~ Kept (kept): events 0 -> 2 (+2)
+  ~ Kept (kept): records 10 -> 12 (+2)
+  ~ Kept (kept): visibility Public -> Private
+  ~ Kept (kept): type Occurrence -> Samplingevent
+  * Kept (kept): published 2018-03-07, previously 2018-03-01
+  + New (new)
+  ~ Grown (grown): occurrences 3 -> 4 (+1)
+  - Gone (gone)
+
+retired
+  - IPT no longer listed
+  - Old (old)
+`
+	if text.String() != wantText {
+		t.Errorf("got \n%s, want \n%s", text, wantText)
+	}
+
+	csv := &bytes.Buffer{}
+	if err := d.WriteCSV(csv); err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(csv.Bytes(), []byte("\n")); lines != len(want)+1 {
+		t.Errorf("got %d csv lines, want %d", lines, len(want)+1)
+	}
+
+	js := &bytes.Buffer{}
+	if err := d.WriteJSON(js); err != nil {
+		t.Fatal(err)
+	}
+	decoded := SnapshotDiff{}
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Changes, want) || !decoded.To.Equal(day(8)) {
+		t.Errorf("json round trip got %#v", decoded)
+	}
+}
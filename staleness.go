@@ -0,0 +1,120 @@
+package iptReport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Reasons a resource is stale.
+const (
+	StaleWindow           = "not republished within window"
+	StaleNeverPublished   = "never published"
+	StaleMissedSchedule   = "missed scheduled publication"
+	StaleUnpublishedEdits = "unpublished edits"
+)
+
+// StaleResource is a Resource of the IPT aliased IPT flagged for Reason,
+// overdue by DaysOverdue: days past the window, past NextPublication, or
+// since LastPublication for unpublished edits.
+type StaleResource struct {
+	IPT         string
+	Resource    Resource
+	Reason      string
+	DaysOverdue int
+}
+
+// StalenessReport lists the stale resources found at Now, grouped by IPT and
+// the most overdue first.
+type StalenessReport struct {
+	Now    time.Time
+	Window time.Duration
+	Stale  []StaleResource
+}
+
+// days returns the whole days in d.
+func days(d time.Duration) int {
+	return int(d.Hours() / 24)
+}
+
+// CheckStaleness flags resources last published longer than window before
+// now, or never published but modified that long ago, resources whose
+// NextPublication is past, and resources modified after their last
+// publication. A zero window skips the first check.
+func CheckStaleness(ipts []IPT, window time.Duration, now time.Time) StalenessReport {
+	report := StalenessReport{Now: now, Window: window}
+
+	for _, ipt := range ipts {
+		stale := []StaleResource{}
+		flag := func(r Resource, reason string, overdue time.Duration) {
+			stale = append(stale, StaleResource{IPT: ipt.Name, Resource: r, Reason: reason, DaysOverdue: days(overdue)})
+		}
+
+		for _, r := range ipt.Resources {
+			if window > 0 {
+				if r.LastPublication.IsZero() {
+					if !r.LastModified.IsZero() && now.Sub(r.LastModified) > window {
+						flag(r, StaleNeverPublished, now.Sub(r.LastModified.Add(window)))
+					}
+				} else if now.Sub(r.LastPublication) > window {
+					flag(r, StaleWindow, now.Sub(r.LastPublication.Add(window)))
+				}
+			}
+			if !r.NextPublication.IsZero() && r.NextPublication.Before(now) {
+				flag(r, StaleMissedSchedule, now.Sub(r.NextPublication))
+			}
+			if !r.LastPublication.IsZero() && r.LastModified.After(r.LastPublication) {
+				flag(r, StaleUnpublishedEdits, r.LastModified.Sub(r.LastPublication))
+			}
+		}
+
+		sort.SliceStable(stale, func(i, j int) bool { return stale[i].DaysOverdue > stale[j].DaysOverdue })
+		report.Stale = append(report.Stale, stale...)
+	}
+
+	return report
+}
+
+// WriteText writes the stale resources as plain text grouped by IPT.
+func (r StalenessReport) WriteText(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "Stale resources at %s", r.Now.Format("2006-01-02"))
+	if r.Window > 0 {
+		fmt.Fprintf(buf, ", republishing window of %d days", days(r.Window))
+	}
+	fmt.Fprintln(buf)
+	if len(r.Stale) == 0 {
+		fmt.Fprintln(buf, "\nNone.")
+	}
+	for i, s := range r.Stale {
+		if i == 0 || r.Stale[i-1].IPT != s.IPT {
+			fmt.Fprintf(buf, "\n%s\n", s.IPT)
+		}
+		fmt.Fprintf(buf, "  %s: %s, %d days overdue\n", s.Resource.Name, s.Reason, s.DaysOverdue)
+	}
+	return buf.Flush()
+}
+
+// WriteCSV writes a line per stale resource and reason.
+func (r StalenessReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"IPT", "Resource Name", "Link", "Reason", "DaysOverdue", "LastModified", "LastPublication", "NextPublication"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for _, s := range r.Stale {
+		line := []string{s.IPT, s.Resource.Name, s.Resource.Link, s.Reason, strconv.Itoa(s.DaysOverdue),
+			formatDate(s.Resource.LastModified), formatDate(s.Resource.LastPublication), formatDate(s.Resource.NextPublication)}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
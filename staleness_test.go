@@ -0,0 +1,72 @@
+package iptReport
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckStaleness(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := func(m, d int) time.Time { return time.Date(2018, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	fresh := Resource{Name: "Fresh", LastModified: day(5, 1), LastPublication: day(5, 1), NextPublication: day(6, 5)}
+	old := Resource{Name: "Old", LastModified: day(1, 1), LastPublication: day(1, 1)}
+	missed := Resource{Name: "Missed", LastModified: day(5, 1), LastPublication: day(5, 1), NextPublication: day(5, 20)}
+	edited := Resource{Name: "Edited", LastModified: day(5, 11), LastPublication: day(5, 1)}
+	draft := Resource{Name: "Draft", LastModified: day(1, 31)}
+	ipts := []IPT{
+		{Name: "a", Resources: []Resource{fresh, old, missed, edited}},
+		{Name: "b", Resources: []Resource{draft}},
+	}
+	window := 90 * 24 * time.Hour
+
+	tableCases := []struct {
+		window time.Duration
+		output []StaleResource
+	}{
+		{window, []StaleResource{
+			{IPT: "a", Resource: old, Reason: StaleWindow, DaysOverdue: 61},
+			{IPT: "a", Resource: missed, Reason: StaleMissedSchedule, DaysOverdue: 12},
+			{IPT: "a", Resource: edited, Reason: StaleUnpublishedEdits, DaysOverdue: 10},
+			{IPT: "b", Resource: draft, Reason: StaleNeverPublished, DaysOverdue: 31},
+		}},
+		{0, []StaleResource{
+			{IPT: "a", Resource: missed, Reason: StaleMissedSchedule, DaysOverdue: 12},
+			{IPT: "a", Resource: edited, Reason: StaleUnpublishedEdits, DaysOverdue: 10},
+		}},
+	}
+
+	for _, tt := range tableCases {
+		if got := CheckStaleness(ipts, tt.window, now).Stale; !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("window %s: got \n%#v, want \n%#v", tt.window, got, tt.output)
+		}
+	}
+
+	report := CheckStaleness(ipts, window, now)
+	buf := &bytes.Buffer{}
+	if err := report.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `Stale resources at 2018-06-01, republishing window of 90 days
+
+a
+  Old: not republished within window, 61 days overdue
+  Missed: missed scheduled publication, 12 days overdue
+  Edited: unpublished edits, 10 days overdue
+
+b
+  Draft: never published, 31 days overdue
+`
+	if buf.String() != want {
+		t.Errorf("got \n%s, want \n%s", buf, want)
+	}
+
+	buf.Reset()
+	if err := report.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 5 {
+		t.Errorf("got %d csv lines, want 5", lines)
+	}
+}
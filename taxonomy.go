@@ -0,0 +1,177 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TaxonRanks are the levels of the TaxonSummary tree, from the top down.
+var TaxonRanks = []string{"kingdom", "phylum", "class", "order", "family", "scientificName"}
+
+// TaxonNode is a taxon of the TaxonSummary tree. Records counts occurrences
+// and IPTs splits them per IPT alias, while Taxa counts checklist entries.
+type TaxonNode struct {
+	Rank     string
+	Name     string
+	Records  int
+	Taxa     int
+	IPTs     map[string]int
+	children map[string]*TaxonNode
+}
+
+// TaxonSummary aggregates occurrence and checklist records from many
+// archives into a taxonomic tree rooted at Root.
+type TaxonSummary struct {
+	Root *TaxonNode
+}
+
+func newTaxonNode(rank, name string) *TaxonNode {
+	return &TaxonNode{Rank: rank, Name: name, IPTs: map[string]int{}, children: map[string]*TaxonNode{}}
+}
+
+// NewTaxonSummary returns an empty summary.
+func NewTaxonSummary() *TaxonSummary {
+	return &TaxonSummary{Root: newTaxonNode("root", "")}
+}
+
+// Children returns the node children sorted by name.
+func (n *TaxonNode) Children() []*TaxonNode {
+	children := make([]*TaxonNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	return children
+}
+
+// Child returns the child called name, nil if absent.
+func (n *TaxonNode) Child(name string) *TaxonNode {
+	return n.children[name]
+}
+
+// MarshalJSON writes the node with its children as a sorted list.
+func (n *TaxonNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Rank     string         `json:"rank"`
+		Name     string         `json:"name"`
+		Records  int            `json:"records"`
+		Taxa     int            `json:"taxa"`
+		IPTs     map[string]int `json:"ipts"`
+		Children []*TaxonNode   `json:"children,omitempty"`
+	}{n.Rank, n.Name, n.Records, n.Taxa, n.IPTs, n.Children()})
+}
+
+// add walks rec down the tree counting it as a record of ipt, or as a taxon
+// when checklist is set.
+func (s *TaxonSummary) add(ipt string, rec Record, checklist bool) {
+	node := s.Root
+	for {
+		if checklist {
+			node.Taxa++
+		} else {
+			node.Records++
+			node.IPTs[ipt]++
+		}
+		if node.Rank == TaxonRanks[len(TaxonRanks)-1] {
+			return
+		}
+
+		rank := TaxonRanks[0]
+		for i, r := range TaxonRanks[:len(TaxonRanks)-1] {
+			if r == node.Rank {
+				rank = TaxonRanks[i+1]
+			}
+		}
+		name := strings.TrimSpace(rec.Get(rank))
+		child, ok := node.children[name]
+		if !ok {
+			child = newTaxonNode(rank, name)
+			node.children[name] = child
+		}
+		node = child
+	}
+}
+
+// AddArchive counts every occurrence record of the archive, at the core or
+// extensions, under ipt. Archives with a taxon core are counted as checklists.
+func (s *TaxonSummary) AddArchive(ipt string, a *Archive) error {
+	for _, f := range a.Files() {
+		checklist := f == a.Core && f.IsRowType(RowTaxon)
+		if !checklist && !f.IsRowType(RowOccurrence) {
+			continue
+		}
+		if err := a.Each(f, func(rec Record) error {
+			s.add(ipt, rec, checklist)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the summary as a JSON tree.
+func (s *TaxonSummary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.Root)
+}
+
+// WriteCSV writes one line per taxon of the tree, parents first, with the
+// records per IPT as alias=count pairs separated by ';'.
+func (s *TaxonSummary) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	titles := append([]string{"Rank"}, TaxonRanks...)
+	titles = append(titles, "Records", "Taxa", "IPTs")
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+
+	var walk func(n *TaxonNode, path []string) error
+	walk = func(n *TaxonNode, path []string) error {
+		if n != s.Root {
+			path = append(path, n.Name)
+			line := make([]string, len(titles))
+			line[0] = n.Rank
+			copy(line[1:], path)
+			line[len(titles)-3] = strconv.Itoa(n.Records)
+			line[len(titles)-2] = strconv.Itoa(n.Taxa)
+			line[len(titles)-1] = formatCounts(n.IPTs)
+			if err := out.Write(line); err != nil {
+				return err
+			}
+		}
+		for _, child := range n.Children() {
+			if err := walk(child, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(s.Root, nil); err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// formatCounts joins counts as key=value pairs sorted by key.
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%d", key, counts[key])
+	}
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,111 @@
+package iptReport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testOccurrenceMeta = `<archive xmlns="http://rs.tdwg.org/dwc/text/">
+  <core encoding="UTF-8" fieldsTerminatedBy="\t" linesTerminatedBy="\n" fieldsEnclosedBy="" ignoreHeaderLines="1" rowType="http://rs.tdwg.org/dwc/terms/Occurrence">
+    <files><location>occurrence.txt</location></files>
+    <id index="0" />
+    <field index="0" term="http://rs.tdwg.org/dwc/terms/occurrenceID"/>
+    <field index="1" term="http://rs.tdwg.org/dwc/terms/kingdom"/>
+    <field index="2" term="http://rs.tdwg.org/dwc/terms/phylum"/>
+    <field index="3" term="http://rs.tdwg.org/dwc/terms/class"/>
+    <field index="4" term="http://rs.tdwg.org/dwc/terms/order"/>
+    <field index="5" term="http://rs.tdwg.org/dwc/terms/family"/>
+    <field index="6" term="http://rs.tdwg.org/dwc/terms/scientificName"/>
+    <field index="7" term="http://rs.tdwg.org/dwc/terms/decimalLatitude"/>
+    <field index="8" term="http://rs.tdwg.org/dwc/terms/decimalLongitude"/>
+    <field index="9" term="http://rs.tdwg.org/dwc/terms/institutionCode"/>
+    <field index="10" term="http://rs.tdwg.org/dwc/terms/catalogNumber"/>
+  </core>
+</archive>`
+
+// testOccurrenceArchive returns an occurrence archive holding rows, each a
+// tab separated line following testOccurrenceMeta.
+func testOccurrenceArchive(rows ...string) map[string]string {
+	return map[string]string{
+		"meta.xml": testOccurrenceMeta,
+		"occurrence.txt": "occurrenceID\tkingdom\tphylum\tclass\torder\tfamily\tscientificName\t" +
+			"decimalLatitude\tdecimalLongitude\tinstitutionCode\tcatalogNumber\n" +
+			strings.Join(rows, "\n") + "\n",
+	}
+}
+
+func TestTaxonSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taxonomy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	birds := writeArchive(t, dir, "birds.zip", testOccurrenceArchive(
+		"b1\tAnimalia\tChordata\tAves\tPasseriformes\tTyrannidae\tPitangus sulphuratus\t-15.8\t-47.9\tUnB\t1",
+		"b2\tAnimalia\tChordata\tAves\tPasseriformes\tTyrannidae\tPitangus sulphuratus\t-15.8\t-47.9\tUnB\t2",
+		"b3\tAnimalia\tChordata\tAves\tPsittaciformes\tPsittacidae\tAra ararauna\t-3.1\t-60.0\tINPA\t3",
+	))
+	cats := writeArchive(t, dir, "cats.zip", testOccurrenceArchive(
+		"c1\tAnimalia\tChordata\tMammalia\tCarnivora\tFelidae\tPuma concolor\t\t\tMPEG\t9",
+	))
+	checklist := writeArchive(t, dir, "checklist.zip", map[string]string{
+		"meta.xml": `<archive><core fieldsTerminatedBy="\t" ignoreHeaderLines="1" rowType="http://rs.tdwg.org/dwc/terms/Taxon">
+  <files><location>taxon.txt</location></files><id index="0"/>
+  <field index="1" term="http://rs.tdwg.org/dwc/terms/kingdom"/>
+  <field index="2" term="http://rs.tdwg.org/dwc/terms/class"/>
+  <field index="3" term="http://rs.tdwg.org/dwc/terms/scientificName"/>
+</core></archive>`,
+		"taxon.txt": "id\tkingdom\tclass\tscientificName\nt1\tAnimalia\tAves\tAra ararauna\n",
+	})
+
+	s := NewTaxonSummary()
+	for _, archive := range []struct{ ipt, path string }{
+		{"sibbr", birds},
+		{"goeldi", cats},
+		{"goeldi", checklist},
+	} {
+		a, err := OpenArchive(archive.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddArchive(archive.ipt, a); err != nil {
+			t.Fatal(err)
+		}
+		a.Close()
+	}
+
+	if s.Root.Records != 4 || s.Root.Taxa != 1 {
+		t.Errorf("got %d records and %d taxa, want 4 and 1", s.Root.Records, s.Root.Taxa)
+	}
+	aves := s.Root.Child("Animalia").Child("Chordata").Child("Aves")
+	if aves == nil || aves.Records != 3 || aves.IPTs["sibbr"] != 3 {
+		t.Errorf("got \n%#v", aves)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"Rank,kingdom,phylum,class,order,family,scientificName,Records,Taxa,IPTs",
+		"class,Animalia,Chordata,Aves,,,,3,0,sibbr=3",
+		"scientificName,Animalia,Chordata,Mammalia,Carnivora,Felidae,Puma concolor,1,0,goeldi=1",
+		"kingdom,Animalia,,,,,,4,1,goeldi=1;sibbr=3",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing line %s at \n%s", line, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := s.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"name": "Aves"`) {
+		t.Errorf("got \n%s", buf.String())
+	}
+}
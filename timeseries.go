@@ -0,0 +1,208 @@
+package iptReport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Levels of aggregation of a time series.
+const (
+	LevelResource     = "resource"
+	LevelIPT          = "ipt"
+	LevelOrganization = "organization"
+	LevelNational     = "national"
+)
+
+// Intervals of a time series.
+const (
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+	IntervalYearly  = "yearly"
+)
+
+// national is the name of the single series of the national level.
+const national = "national"
+
+// PeriodStart returns the start of the period of interval holding t, weeks
+// starting on Mondays, in the location of t.
+func PeriodStart(t time.Time, interval string) (time.Time, error) {
+	y, m, d := t.Date()
+	switch interval {
+	case IntervalWeekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location()), nil
+	case IntervalMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location()), nil
+	case IntervalYearly:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("Unknown interval %s", interval)
+}
+
+// SeriesPoint holds the totals of a Series at the period starting at Period.
+type SeriesPoint struct {
+	Period       time.Time
+	Series       string
+	Resources    int
+	Occurrences  int
+	Events       int
+	Measurements int
+}
+
+// TimeSeries holds the points of every series of a level, sorted by period
+// and series.
+type TimeSeries struct {
+	Level    string
+	Interval string
+	Points   []SeriesPoint
+}
+
+// seriesName returns the series of level a resource of ipt belongs to.
+// Resources are named by their ResourceKey, organizations by their key, see
+// BuildTimeSeries for their name.
+func seriesName(level string, ipt string, r Resource, orgs *OrgNormalizer) (string, error) {
+	switch level {
+	case LevelResource:
+		if k := r.Key(); k != "" {
+			return string(k), nil
+		}
+		return ipt + "/" + resourceID(r), nil
+	case LevelIPT:
+		return ipt, nil
+	case LevelOrganization:
+		return orgs.Key(r.Organization), nil
+	case LevelNational:
+		return national, nil
+	}
+	return "", fmt.Errorf("Unknown level %s", level)
+}
+
+// carryForward returns the resources of each IPT at each snapshot, IPTs
+// failing at a snapshot keeping those of their last successful crawl.
+func carryForward(snapshots []*Snapshot) []map[string][]Resource {
+	states := []map[string][]Resource{}
+	lastGood := map[string][]Resource{}
+	for _, s := range snapshots {
+		for _, ipt := range s.IPTs {
+			if ipt.Healthy() {
+				lastGood[ipt.Name] = ipt.Resources
+			}
+		}
+		ipts := map[string][]Resource{}
+		for _, ipt := range s.IPTs {
+			ipts[ipt.Name] = lastGood[ipt.Name]
+		}
+		states = append(states, ipts)
+	}
+	return states
+}
+
+// BuildTimeSeries totals the resources of snapshots, oldest first, per series
+// of level and period of interval. Each period takes the latest snapshot
+// taken in it. IPTs failing at a snapshot count the resources of their last
+// successful crawl. Organizations are grouped by orgs and named by their
+// canonical name, or else the first spelling seen.
+func BuildTimeSeries(snapshots []*Snapshot, level, interval string, orgs *OrgNormalizer) (*TimeSeries, error) {
+	if _, err := seriesName(level, "", Resource{}, orgs); err != nil {
+		return nil, err
+	}
+
+	// the last snapshot of each period, with failing IPTs filled in
+	type period struct {
+		start time.Time
+		ipts  map[string][]Resource
+	}
+	periods := []period{}
+	for i, ipts := range carryForward(snapshots) {
+		start, err := PeriodStart(snapshots[i].Taken, interval)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(periods); n > 0 && periods[n-1].start.Equal(start) {
+			periods[n-1].ipts = ipts
+		} else {
+			periods = append(periods, period{start: start, ipts: ipts})
+		}
+	}
+
+	display := map[string]string{}
+	ts := &TimeSeries{Level: level, Interval: interval}
+	for _, p := range periods {
+		ipts := make([]string, 0, len(p.ipts))
+		for ipt := range p.ipts {
+			ipts = append(ipts, ipt)
+		}
+		sort.Strings(ipts)
+
+		points := map[string]*SeriesPoint{}
+		for _, ipt := range ipts {
+			for _, r := range p.ipts[ipt] {
+				name, _ := seriesName(level, ipt, r, orgs)
+				if level == LevelOrganization {
+					if _, ok := display[name]; !ok {
+						display[name] = r.Organization
+						if canonical, ok := orgs.Canonical(r.Organization); ok {
+							display[name] = canonical
+						}
+					}
+					name = display[name]
+				}
+				point, ok := points[name]
+				if !ok {
+					point = &SeriesPoint{Period: p.start, Series: name}
+					points[name] = point
+				}
+				point.Resources++
+				point.Occurrences += r.Occurrences
+				point.Events += r.Events
+				point.Measurements += r.Measurements
+			}
+		}
+
+		names := make([]string, 0, len(points))
+		for name := range points {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ts.Points = append(ts.Points, *points[name])
+		}
+	}
+
+	return ts, nil
+}
+
+// Series returns the points of the series name.
+func (ts *TimeSeries) Series(name string) []SeriesPoint {
+	points := []SeriesPoint{}
+	for _, p := range ts.Points {
+		if p.Series == name {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// WriteCSV writes a line per point, in long format for charting tools.
+func (ts *TimeSeries) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	titles := []string{"Period", "Level", "Series", "Resources", "Occurrences", "Events", "Measurements"}
+	if err := out.Write(titles); err != nil {
+		return err
+	}
+	for _, p := range ts.Points {
+		line := []string{formatDate(p.Period), ts.Level, p.Series, strconv.Itoa(p.Resources),
+			strconv.Itoa(p.Occurrences), strconv.Itoa(p.Events), strconv.Itoa(p.Measurements)}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
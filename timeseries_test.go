@@ -0,0 +1,115 @@
+package iptReport
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	wednesday := time.Date(2018, 3, 14, 15, 30, 0, 0, time.UTC)
+	tableCases := []struct {
+		input       string
+		output      time.Time
+		shouldError bool
+	}{
+		{IntervalWeekly, time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC), false},
+		{IntervalMonthly, time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{IntervalYearly, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"daily", time.Time{}, true},
+	}
+
+	for _, tt := range tableCases {
+		got, err := PeriodStart(wednesday, tt.input)
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error for interval %s", tt.input)
+		} else if !got.Equal(tt.output) {
+			t.Errorf("%s: got %s, want %s", tt.input, got, tt.output)
+		}
+	}
+	if got, _ := PeriodStart(time.Date(2018, 3, 18, 0, 0, 0, 0, time.UTC), IntervalWeekly); got.Day() != 12 {
+		t.Errorf("sunday belongs to the week starting on %s", got)
+	}
+}
+
+func TestBuildTimeSeries(t *testing.T) {
+	link := func(r string) string { return "https://ipt.example.org/resource?r=" + r }
+	day := func(m, d int) time.Time { return time.Date(2018, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	snapshots := []*Snapshot{
+		{Taken: day(1, 10), IPTs: []IPTSnapshot{
+			{Name: "a", Resources: []Resource{{Link: link("x"), Organization: "SiBBr", Occurrences: 1}}},
+		}},
+		{Taken: day(1, 20), IPTs: []IPTSnapshot{
+			{Name: "a", Resources: []Resource{{Link: link("x"), Organization: "SiBBr", Occurrences: 10, Events: 1}}},
+			{Name: "b", Resources: []Resource{{Link: link("y"), Organization: "sibbr", Occurrences: 5}}},
+		}},
+		{Taken: day(2, 5), IPTs: []IPTSnapshot{
+			{Name: "a", Resources: []Resource{
+				{Link: link("x"), Organization: "SiBBr", Occurrences: 12, Events: 1},
+				{Link: link("z"), Organization: "INPA", Occurrences: 3, Measurements: 2},
+			}},
+			{Name: "b", Err: "connection refused"},
+		}},
+	}
+
+	tableCases := []struct {
+		level  string
+		output []SeriesPoint
+	}{
+		{LevelNational, []SeriesPoint{
+			{Period: day(1, 1), Series: "national", Resources: 2, Occurrences: 15, Events: 1},
+			{Period: day(2, 1), Series: "national", Resources: 3, Occurrences: 20, Events: 1, Measurements: 2},
+		}},
+		{LevelIPT, []SeriesPoint{
+			{Period: day(1, 1), Series: "a", Resources: 1, Occurrences: 10, Events: 1},
+			{Period: day(1, 1), Series: "b", Resources: 1, Occurrences: 5},
+			{Period: day(2, 1), Series: "a", Resources: 2, Occurrences: 15, Events: 1, Measurements: 2},
+			{Period: day(2, 1), Series: "b", Resources: 1, Occurrences: 5},
+		}},
+		{LevelOrganization, []SeriesPoint{
+			{Period: day(1, 1), Series: "SiBBr", Resources: 2, Occurrences: 15, Events: 1},
+			{Period: day(2, 1), Series: "INPA", Resources: 1, Occurrences: 3, Measurements: 2},
+			{Period: day(2, 1), Series: "SiBBr", Resources: 2, Occurrences: 17, Events: 1},
+		}},
+		{LevelResource, []SeriesPoint{
+			{Period: day(1, 1), Series: "ipt.example.org?r=x", Resources: 1, Occurrences: 10, Events: 1},
+			{Period: day(1, 1), Series: "ipt.example.org?r=y", Resources: 1, Occurrences: 5},
+			{Period: day(2, 1), Series: "ipt.example.org?r=x", Resources: 1, Occurrences: 12, Events: 1},
+			{Period: day(2, 1), Series: "ipt.example.org?r=y", Resources: 1, Occurrences: 5},
+			{Period: day(2, 1), Series: "ipt.example.org?r=z", Resources: 1, Occurrences: 3, Measurements: 2},
+		}},
+	}
+
+	for _, tt := range tableCases {
+		ts, err := BuildTimeSeries(snapshots, tt.level, IntervalMonthly, NewOrgNormalizer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ts.Points, tt.output) {
+			t.Errorf("%s: got \n%#v, want \n%#v", tt.level, ts.Points, tt.output)
+		}
+	}
+
+	if _, err := BuildTimeSeries(snapshots, "continental", IntervalMonthly, NewOrgNormalizer()); err == nil {
+		t.Errorf("expected error for an unknown level")
+	}
+	if _, err := BuildTimeSeries(snapshots, LevelIPT, "daily", NewOrgNormalizer()); err == nil {
+		t.Errorf("expected error for an unknown interval")
+	}
+
+	ts, _ := BuildTimeSeries(snapshots, LevelNational, IntervalYearly, NewOrgNormalizer())
+	if len(ts.Series("national")) != 1 {
+		t.Errorf("got %d yearly points, want 1", len(ts.Series("national")))
+	}
+	buf := &bytes.Buffer{}
+	if err := ts.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "Period,Level,Series,Resources,Occurrences,Events,Measurements\n2018-01-01,national,national,3,20,1,2\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,533 @@
+package iptReport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kinds of Term.
+const (
+	TermIRI = iota
+	TermBlank
+	TermLiteral
+)
+
+// Term is a node of an RDF graph. Lang and Datatype are only set for
+// literals, Datatype as a full IRI.
+type Term struct {
+	Kind     int
+	Value    string
+	Lang     string
+	Datatype string
+}
+
+// Triple is a statement of an RDF graph.
+type Triple struct {
+	Subject, Predicate, Object Term
+}
+
+const rdfType = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+
+// turtleParser reads the subset of Turtle published by IPTs: prefixes,
+// predicate and object lists, blank node property lists and literals with
+// language or datatype. Collections are not supported.
+type turtleParser struct {
+	src      []rune
+	pos      int
+	base     string
+	prefixes map[string]string
+	blanks   int
+	triples  []Triple
+}
+
+// ParseTurtle reads every triple of a Turtle document.
+func ParseTurtle(r io.Reader) ([]Triple, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &turtleParser{src: []rune(string(data)), prefixes: map[string]string{}}
+	for {
+		p.skip()
+		if p.pos >= len(p.src) {
+			return p.triples, nil
+		}
+		if err := p.statement(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *turtleParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(string(p.src[:p.pos]), "\n")
+	return fmt.Errorf("turtle line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skip jumps over white space and comments.
+func (p *turtleParser) skip() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if !unicode.IsSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *turtleParser) peek() rune {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *turtleParser) expect(c rune) error {
+	p.skip()
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *turtleParser) hasKeyword(keyword string) bool {
+	end := p.pos + len(keyword)
+	if end > len(p.src) || !strings.EqualFold(string(p.src[p.pos:end]), keyword) {
+		return false
+	}
+	return end == len(p.src) || unicode.IsSpace(p.src[end]) || strings.ContainsRune("<.;,]", p.src[end])
+}
+
+func (p *turtleParser) statement() error {
+	switch {
+	case p.hasKeyword("@prefix") || p.hasKeyword("prefix"):
+		sparql := p.peek() != '@'
+		p.pos += len("prefix")
+		if !sparql {
+			p.pos++
+		}
+		p.skip()
+		start := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] != ':' {
+			p.pos++
+		}
+		prefix := strings.TrimSpace(string(p.src[start:p.pos]))
+		p.pos++
+		p.skip()
+		iri, err := p.iri()
+		if err != nil {
+			return err
+		}
+		p.prefixes[prefix] = iri
+		if !sparql {
+			return p.expect('.')
+		}
+		return nil
+	case p.hasKeyword("@base") || p.hasKeyword("base"):
+		sparql := p.peek() != '@'
+		p.pos += len("base")
+		if !sparql {
+			p.pos++
+		}
+		p.skip()
+		iri, err := p.iri()
+		if err != nil {
+			return err
+		}
+		p.base = iri
+		if !sparql {
+			return p.expect('.')
+		}
+		return nil
+	}
+
+	var subject Term
+	var err error
+	if p.peek() == '[' {
+		p.pos++
+		subject = p.blank()
+		if err := p.propertyList(subject, ']'); err != nil {
+			return err
+		}
+		p.skip()
+		if p.peek() == '.' {
+			p.pos++
+			return nil
+		}
+	} else if subject, err = p.resource(); err != nil {
+		return err
+	}
+
+	return p.propertyList(subject, '.')
+}
+
+func (p *turtleParser) blank() Term {
+	p.blanks++
+	return Term{Kind: TermBlank, Value: fmt.Sprintf("anon%d", p.blanks)}
+}
+
+// propertyList reads predicate object lists of subject up to end.
+func (p *turtleParser) propertyList(subject Term, end rune) error {
+	for {
+		p.skip()
+		if p.peek() == end {
+			p.pos++
+			return nil
+		}
+
+		var predicate Term
+		if p.peek() == 'a' && p.pos+1 < len(p.src) && unicode.IsSpace(p.src[p.pos+1]) {
+			p.pos++
+			predicate = Term{Kind: TermIRI, Value: rdfType}
+		} else {
+			var err error
+			if predicate, err = p.resource(); err != nil {
+				return err
+			}
+		}
+
+		for {
+			p.skip()
+			object, err := p.object()
+			if err != nil {
+				return err
+			}
+			p.triples = append(p.triples, Triple{subject, predicate, object})
+			p.skip()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+
+		p.skip()
+		switch p.peek() {
+		case ';':
+			for p.peek() == ';' {
+				p.pos++
+				p.skip()
+			}
+		case end:
+		default:
+			return p.errorf("expected ';' or %q", end)
+		}
+	}
+}
+
+func (p *turtleParser) object() (Term, error) {
+	switch c := p.peek(); {
+	case c == '[':
+		p.pos++
+		node := p.blank()
+		return node, p.propertyList(node, ']')
+	case c == '"' || c == '\'':
+		return p.literal()
+	case c == '+' || c == '-' || unicode.IsDigit(c):
+		start := p.pos
+		for p.pos < len(p.src) && strings.ContainsRune("+-.eE0123456789", p.src[p.pos]) {
+			p.pos++
+		}
+		// a trailing dot ends the statement
+		if p.src[p.pos-1] == '.' {
+			p.pos--
+		}
+		value := string(p.src[start:p.pos])
+		datatype := "http://www.w3.org/2001/XMLSchema#integer"
+		if strings.ContainsAny(value, "eE") {
+			datatype = "http://www.w3.org/2001/XMLSchema#double"
+		} else if strings.Contains(value, ".") {
+			datatype = "http://www.w3.org/2001/XMLSchema#decimal"
+		}
+		return Term{Kind: TermLiteral, Value: value, Datatype: datatype}, nil
+	case p.hasKeyword("true") || p.hasKeyword("false"):
+		value := "true"
+		if c == 'f' {
+			value = "false"
+		}
+		p.pos += len(value)
+		return Term{Kind: TermLiteral, Value: value, Datatype: "http://www.w3.org/2001/XMLSchema#boolean"}, nil
+	}
+	return p.resource()
+}
+
+// resource reads an IRI, a prefixed name or a blank node label.
+func (p *turtleParser) resource() (Term, error) {
+	p.skip()
+	if p.peek() == '<' {
+		iri, err := p.iri()
+		return Term{Kind: TermIRI, Value: iri}, err
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if unicode.IsSpace(c) || strings.ContainsRune(",;[]()<>\"", c) {
+			break
+		}
+		// a dot is only part of a name when followed by more of it
+		if c == '.' && (p.pos+1 >= len(p.src) || unicode.IsSpace(p.src[p.pos+1])) {
+			break
+		}
+		p.pos++
+	}
+	name := string(p.src[start:p.pos])
+	if name == "" {
+		return Term{}, p.errorf("expected IRI")
+	}
+
+	if strings.HasPrefix(name, "_:") {
+		return Term{Kind: TermBlank, Value: name[2:]}, nil
+	}
+	i := strings.Index(name, ":")
+	if i < 0 {
+		return Term{}, p.errorf("unknown name %q", name)
+	}
+	ns, ok := p.prefixes[name[:i]]
+	if !ok {
+		return Term{}, p.errorf("unknown prefix %q", name[:i])
+	}
+	return Term{Kind: TermIRI, Value: ns + name[i+1:]}, nil
+}
+
+func (p *turtleParser) iri() (string, error) {
+	if p.peek() != '<' {
+		return "", p.errorf("expected '<'")
+	}
+	p.pos++
+	value := []rune{}
+	for p.pos < len(p.src) && p.src[p.pos] != '>' {
+		c := p.src[p.pos]
+		if c == '\\' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == 'u' || p.src[p.pos+1] == 'U') {
+			size := 4
+			if p.src[p.pos+1] == 'U' {
+				size = 8
+			}
+			if p.pos+2+size > len(p.src) {
+				return "", p.errorf("bad escape")
+			}
+			code, err := strconv.ParseUint(string(p.src[p.pos+2:p.pos+2+size]), 16, 32)
+			if err != nil {
+				return "", p.errorf("bad escape")
+			}
+			value = append(value, rune(code))
+			p.pos += 2 + size
+			continue
+		}
+		value = append(value, c)
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return "", p.errorf("unterminated IRI")
+	}
+	iri := string(value)
+	p.pos++
+	if p.base != "" && !strings.Contains(iri, ":") {
+		iri = p.base + iri
+	}
+	return iri, nil
+}
+
+func (p *turtleParser) literal() (Term, error) {
+	quote := p.src[p.pos]
+	long := p.pos+2 < len(p.src) && p.src[p.pos+1] == quote && p.src[p.pos+2] == quote
+	if long {
+		p.pos += 3
+	} else {
+		p.pos++
+	}
+
+	value := []rune{}
+	for {
+		if p.pos >= len(p.src) {
+			return Term{}, p.errorf("unterminated literal")
+		}
+		c := p.src[p.pos]
+		if c == '\\' && p.pos+1 < len(p.src) {
+			p.pos++
+			switch e := p.src[p.pos]; e {
+			case 't':
+				value = append(value, '\t')
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 'b':
+				value = append(value, '\b')
+			case 'f':
+				value = append(value, '\f')
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if p.pos+size >= len(p.src) {
+					return Term{}, p.errorf("bad escape")
+				}
+				code, err := strconv.ParseUint(string(p.src[p.pos+1:p.pos+1+size]), 16, 32)
+				if err != nil {
+					return Term{}, p.errorf("bad escape")
+				}
+				value = append(value, rune(code))
+				p.pos += size
+			default:
+				value = append(value, e)
+			}
+			p.pos++
+			continue
+		}
+		if long && c == quote && p.pos+2 < len(p.src) && p.src[p.pos+1] == quote && p.src[p.pos+2] == quote {
+			p.pos += 3
+			break
+		}
+		if !long && c == quote {
+			p.pos++
+			break
+		}
+		value = append(value, c)
+		p.pos++
+	}
+
+	term := Term{Kind: TermLiteral, Value: string(value)}
+	switch {
+	case p.peek() == '@':
+		start := p.pos + 1
+		p.pos++
+		for p.pos < len(p.src) && (unicode.IsLetter(p.src[p.pos]) || unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		term.Lang = string(p.src[start:p.pos])
+	case p.peek() == '^' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '^':
+		p.pos += 2
+		datatype, err := p.resource()
+		if err != nil {
+			return Term{}, err
+		}
+		term.Datatype = datatype.Value
+	}
+	return term, nil
+}
+
+// turtleQuote quotes s as a Turtle string literal.
+func turtleQuote(s string) string {
+	b := &strings.Builder{}
+	b.WriteByte('"')
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(b, `\u%04X`, c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// turtleIRI writes iri between angle brackets, escaping the characters
+// Turtle doesn't allow in IRIs.
+func turtleIRI(iri string) string {
+	b := &strings.Builder{}
+	b.WriteByte('<')
+	for _, c := range iri {
+		if c <= 0x20 || c == 0x7f || strings.ContainsRune("<>\"{}|^`\\", c) {
+			fmt.Fprintf(b, `\u%04X`, c)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// notLocalName reports whether c can't be written unescaped in the local
+// part of a prefixed name.
+func notLocalName(c rune) bool {
+	return !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-'
+}
+
+// turtleWriter writes Turtle statements grouped by subject.
+type turtleWriter struct {
+	w        *bufio.Writer
+	prefixes [][2]string
+}
+
+func newTurtleWriter(w io.Writer, prefixes [][2]string) *turtleWriter {
+	tw := &turtleWriter{w: bufio.NewWriter(w), prefixes: prefixes}
+	for _, prefix := range prefixes {
+		fmt.Fprintf(tw.w, "@prefix %s: %s .\n", prefix[0], turtleIRI(prefix[1]))
+	}
+	return tw
+}
+
+// term formats t, compacting IRIs with the known prefixes.
+func (tw *turtleWriter) term(t Term) string {
+	switch t.Kind {
+	case TermBlank:
+		return "_:" + t.Value
+	case TermLiteral:
+		s := turtleQuote(t.Value)
+		if t.Lang != "" {
+			return s + "@" + t.Lang
+		}
+		if t.Datatype != "" {
+			return s + "^^" + tw.term(Term{Kind: TermIRI, Value: t.Datatype})
+		}
+		return s
+	}
+	for _, prefix := range tw.prefixes {
+		local := strings.TrimPrefix(t.Value, prefix[1])
+		if local != t.Value && local != "" && local[0] != '-' && strings.IndexFunc(local, notLocalName) < 0 {
+			return prefix[0] + ":" + local
+		}
+	}
+	return turtleIRI(t.Value)
+}
+
+// predicate is term with rdf:type shortened to a, only allowed as predicate.
+func (tw *turtleWriter) predicate(t Term) string {
+	if t.Kind == TermIRI && t.Value == rdfType {
+		return "a"
+	}
+	return tw.term(t)
+}
+
+// subject writes all statements of a subject, which must come in order.
+func (tw *turtleWriter) subject(triples []Triple) {
+	if len(triples) == 0 {
+		return
+	}
+	fmt.Fprintf(tw.w, "\n%s", tw.term(triples[0].Subject))
+	for i, t := range triples {
+		sep := " ;"
+		if i == len(triples)-1 {
+			sep = " ."
+		}
+		fmt.Fprintf(tw.w, "\n    %s %s%s", tw.predicate(t.Predicate), tw.term(t.Object), sep)
+	}
+	tw.w.WriteString("\n")
+}
+
+func (tw *turtleWriter) flush() error {
+	return tw.w.Flush()
+}
@@ -0,0 +1,92 @@
+package iptReport
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTurtle(t *testing.T) {
+	iri := func(s string) Term { return Term{Kind: TermIRI, Value: s} }
+
+	tableCases := []struct {
+		input       string
+		output      []Triple
+		shouldError bool
+	}{
+		{
+			`@prefix ex: <http://example.org/> .
+PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+ex:a a ex:Thing ; ex:name "A"@en , 'B' ; ex:n 12 ; ex:ok true .`,
+			[]Triple{
+				{iri("http://example.org/a"), iri(rdfType), iri("http://example.org/Thing")},
+				{iri("http://example.org/a"), iri("http://example.org/name"), Term{Kind: TermLiteral, Value: "A", Lang: "en"}},
+				{iri("http://example.org/a"), iri("http://example.org/name"), Term{Kind: TermLiteral, Value: "B"}},
+				{iri("http://example.org/a"), iri("http://example.org/n"), Term{Kind: TermLiteral, Value: "12", Datatype: nsXSD + "integer"}},
+				{iri("http://example.org/a"), iri("http://example.org/ok"), Term{Kind: TermLiteral, Value: "true", Datatype: nsXSD + "boolean"}},
+			},
+			false,
+		},
+		{
+			`<http://example.org/a> <http://example.org/p> [ <http://example.org/q> "x\ty" ] .`,
+			[]Triple{
+				{Term{Kind: TermBlank, Value: "anon1"}, iri("http://example.org/q"), Term{Kind: TermLiteral, Value: "x\ty"}},
+				{iri("http://example.org/a"), iri("http://example.org/p"), Term{Kind: TermBlank, Value: "anon1"}},
+			},
+			false,
+		},
+		{`ex:a ex:b ex:c .`, nil, true},
+		{`<http://example.org/a> <http://example.org/p> "open .`, nil, true},
+		{`<http://example.org/a> <http://example.org/p> <http://example.org/o>`, nil, true},
+	}
+
+	for _, tt := range tableCases {
+		r, err := ParseTurtle(strings.NewReader(tt.input))
+		if err != nil && tt.shouldError == false {
+			t.Fatal(err)
+		} else if err == nil && tt.shouldError {
+			t.Errorf("expected error parsing %s", tt.input)
+		} else if !reflect.DeepEqual(r, tt.output) && tt.shouldError == false {
+			t.Errorf("got \n%#v, want \n%#v", r, tt.output)
+		}
+	}
+}
+
+func TestTurtleWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := newTurtleWriter(buf, [][2]string{{"ex", "http://example.org/"}})
+	a := Term{Kind: TermIRI, Value: "http://example.org/a"}
+	tw.subject([]Triple{
+		{a, Term{Kind: TermIRI, Value: rdfType}, Term{Kind: TermIRI, Value: "http://example.org/Thing"}},
+		{a, Term{Kind: TermIRI, Value: "http://example.org/name"}, Term{Kind: TermLiteral, Value: "say \"hi\"\n", Lang: "en"}},
+		{a, Term{Kind: TermIRI, Value: "http://example.org/page"}, Term{Kind: TermIRI, Value: "http://example.org/r?x=1"}},
+		{a, Term{Kind: TermIRI, Value: "http://example.org/see"}, Term{Kind: TermIRI, Value: rdfType}},
+		{a, Term{Kind: TermIRI, Value: "http://example.org/file"}, Term{Kind: TermIRI, Value: "http://example.org/a b<c>"}},
+	})
+	if err := tw.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `@prefix ex: <http://example.org/> .
+
+ex:a
+    a ex:Thing ;
+    ex:name "say \"hi\"\n"@en ;
+    ex:page <http://example.org/r?x=1> ;
+    ex:see <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> ;
+    ex:file <http://example.org/a\u0020b\u003Cc\u003E> .
+`
+	if buf.String() != want {
+		t.Errorf("got \n%s, want \n%s", buf.String(), want)
+	}
+
+	triples, err := ParseTurtle(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triples) != 5 || triples[1].Object.Value != "say \"hi\"\n" || triples[1].Object.Lang != "en" ||
+		triples[3].Object.Value != rdfType || triples[4].Object.Value != "http://example.org/a b<c>" {
+		t.Errorf("got \n%#v", triples)
+	}
+}
@@ -0,0 +1,134 @@
+package iptReport
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Verification statuses of a resource archive.
+const (
+	VerifyOK       = "ok"
+	VerifyMismatch = "mismatch"
+	VerifyMissing  = "missing"
+	VerifyEmpty    = "empty"
+	VerifyCorrupt  = "corrupt"
+)
+
+// ArchiveCounts holds the number of rows of an archive per data file type.
+// Core is the row count of the core data file whatever its type.
+type ArchiveCounts struct {
+	Core         int
+	Occurrences  int
+	Events       int
+	Measurements int
+}
+
+// Discrepancy is a count published by the IPT which doesn't match the archive.
+type Discrepancy struct {
+	Field     string
+	Published int
+	Archive   int
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s: published %d, archive %d", d.Field, d.Published, d.Archive)
+}
+
+// Verification is the result of checking a Resource against its archive.
+// Err explains why the archive couldn't be read for statuses other than
+// VerifyOK and VerifyMismatch.
+type Verification struct {
+	Resource      Resource
+	Path          string
+	Status        string
+	Counts        ArchiveCounts
+	Discrepancies []Discrepancy
+	Err           error
+}
+
+// CountArchive counts the rows of every data file of the archive.
+func CountArchive(a *Archive) (counts ArchiveCounts, err error) {
+	if counts.Core, err = a.Count(a.Core); err != nil {
+		return
+	}
+
+	for _, f := range a.Files() {
+		n := counts.Core
+		if f != a.Core {
+			if n, err = a.Count(f); err != nil {
+				return
+			}
+		}
+		switch {
+		case f.IsRowType(RowOccurrence):
+			counts.Occurrences += n
+		case f.IsRowType(RowEvent):
+			counts.Events += n
+		case f.IsRowType(RowMeasurementOrFact):
+			counts.Measurements += n
+		}
+	}
+
+	return
+}
+
+// VerifyResource compares the counts the IPT published for r, both at the
+// home page records column and from CrawlResource, with the archive at path.
+func VerifyResource(r Resource, path string) Verification {
+	v := Verification{Resource: r, Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		v.Status, v.Err = VerifyMissing, err
+		return v
+	}
+	if info.Size() == 0 {
+		v.Status, v.Err = VerifyEmpty, fmt.Errorf("Archive %s is empty", path)
+		return v
+	}
+
+	a, err := OpenArchive(path)
+	if err != nil {
+		v.Status, v.Err = VerifyCorrupt, err
+		return v
+	}
+	defer a.Close()
+
+	if v.Counts, err = CountArchive(a); err != nil {
+		v.Status, v.Err = VerifyCorrupt, err
+		return v
+	}
+
+	compare := func(field string, published, archive int) {
+		if published != archive {
+			v.Discrepancies = append(v.Discrepancies, Discrepancy{field, published, archive})
+		}
+	}
+	compare("Records", r.Records, v.Counts.Core)
+	// without an occurrence table Occurrences is just the records column
+	if v.Counts.Occurrences != 0 || r.Occurrences != r.Records {
+		compare("Occurrences", r.Occurrences, v.Counts.Occurrences)
+	}
+	compare("Events", r.Events, v.Counts.Events)
+	compare("Measurements", r.Measurements, v.Counts.Measurements)
+
+	v.Status = VerifyOK
+	if len(v.Discrepancies) > 0 {
+		v.Status = VerifyMismatch
+	}
+
+	return v
+}
+
+// Describe summarizes the discrepancies or the error of the verification.
+func (v Verification) Describe() string {
+	if v.Err != nil {
+		return v.Err.Error()
+	}
+	msgs := make([]string, len(v.Discrepancies))
+	for i, d := range v.Discrepancies {
+		msgs[i] = d.String()
+	}
+	return strings.Join(msgs, "; ")
+}
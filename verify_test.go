@@ -0,0 +1,64 @@
+package iptReport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := writeArchive(t, dir, "valid.zip", testArchive)
+	empty := filepath.Join(dir, "empty.zip")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.zip")
+	if err := ioutil.WriteFile(corrupt, []byte("<html>Not found</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	counts := ArchiveCounts{Core: 2, Occurrences: 3, Events: 2, Measurements: 1}
+
+	tableCases := []struct {
+		resource      Resource
+		path          string
+		status        string
+		discrepancies []Discrepancy
+	}{
+		{
+			Resource{Records: 2, Events: 2, Occurrences: 3, Measurements: 1},
+			valid,
+			VerifyOK,
+			nil,
+		},
+		{
+			Resource{Records: 5, Events: 2, Occurrences: 4, Measurements: 1},
+			valid,
+			VerifyMismatch,
+			[]Discrepancy{{"Records", 5, 2}, {"Occurrences", 4, 3}},
+		},
+		{Resource{}, filepath.Join(dir, "missing.zip"), VerifyMissing, nil},
+		{Resource{}, empty, VerifyEmpty, nil},
+		{Resource{}, corrupt, VerifyCorrupt, nil},
+	}
+
+	for _, tt := range tableCases {
+		v := VerifyResource(tt.resource, tt.path)
+		if v.Status != tt.status {
+			t.Errorf("%s: got status %s, want %s (%v)", tt.path, v.Status, tt.status, v.Err)
+		}
+		if !reflect.DeepEqual(v.Discrepancies, tt.discrepancies) {
+			t.Errorf("got \n%#v, want \n%#v", v.Discrepancies, tt.discrepancies)
+		}
+		if v.Path == valid && v.Counts != counts {
+			t.Errorf("got \n%#v, want \n%#v", v.Counts, counts)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package iptReport
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Kinds of RecordChange.
+const (
+	RecordAdded     = "added"
+	RecordRemoved   = "removed"
+	RecordModified  = "modified"
+	RecordDuplicate = "duplicate"
+)
+
+// RecordChange is a difference of a core record between two archives. Term,
+// Old and New are only set for modified records, one change per term.
+type RecordChange struct {
+	ID     string
+	Change string
+	Term   string
+	Old    string
+	New    string
+}
+
+// ArchiveDiff counts core records by how they changed between two archives.
+// Duplicates counts records of either archive repeating a core id already seen
+// in it, which are left out of the comparison.
+type ArchiveDiff struct {
+	Added      int
+	Removed    int
+	Modified   int
+	Unchanged  int
+	Duplicates int
+}
+
+// DiffArchives compares the core records of two versions of an archive, from
+// the older to the newer, matching them by core id. When details isn't nil it
+// is called with every change found, removed records last. Only the first
+// record of each core id is compared; repeated ones are reported as
+// duplicates.
+func DiffArchives(from, to *Archive, details func(RecordChange) error) (ArchiveDiff, error) {
+	diff := ArchiveDiff{}
+	if from.Core.ID < 0 || to.Core.ID < 0 {
+		return diff, fmt.Errorf("Can't match records of a core without id")
+	}
+	if details == nil {
+		details = func(RecordChange) error { return nil }
+	}
+
+	previous := map[string]map[string]string{}
+	if err := from.Each(from.Core, func(rec Record) error {
+		if _, ok := previous[rec.ID]; ok {
+			diff.Duplicates++
+			return details(RecordChange{ID: rec.ID, Change: RecordDuplicate})
+		}
+		previous[rec.ID] = rec.Terms
+		return nil
+	}); err != nil {
+		return diff, err
+	}
+
+	seen := map[string]bool{}
+	err := to.Each(to.Core, func(rec Record) error {
+		if seen[rec.ID] {
+			diff.Duplicates++
+			return details(RecordChange{ID: rec.ID, Change: RecordDuplicate})
+		}
+		seen[rec.ID] = true
+
+		terms, ok := previous[rec.ID]
+		if !ok {
+			diff.Added++
+			return details(RecordChange{ID: rec.ID, Change: RecordAdded})
+		}
+		delete(previous, rec.ID)
+
+		names := make([]string, 0, len(terms))
+		for name := range terms {
+			names = append(names, name)
+		}
+		for name := range rec.Terms {
+			if _, ok := terms[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		modified := false
+		for _, name := range names {
+			if terms[name] == rec.Terms[name] {
+				continue
+			}
+			modified = true
+			change := RecordChange{rec.ID, RecordModified, name, terms[name], rec.Terms[name]}
+			if err := details(change); err != nil {
+				return err
+			}
+		}
+		if modified {
+			diff.Modified++
+		} else {
+			diff.Unchanged++
+		}
+		return nil
+	})
+	if err != nil {
+		return diff, err
+	}
+
+	removed := make([]string, 0, len(previous))
+	for id := range previous {
+		removed = append(removed, id)
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		diff.Removed++
+		if err := details(RecordChange{ID: id, Change: RecordRemoved}); err != nil {
+			return diff, err
+		}
+	}
+
+	return diff, nil
+}
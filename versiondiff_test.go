@@ -0,0 +1,66 @@
+package iptReport
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDiffArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "versiondiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	from, err := OpenArchive(writeArchive(t, dir, "1.0.zip", testOccurrenceArchive(
+		"o1\tAnimalia\t\t\t\t\tAra ararauna\t\t\t\t",
+		"o2\tAnimalia\t\t\t\t\tPuma concolor\t\t\t\t",
+		"o3\tAnimalia\t\t\t\t\tTapirus terrestris\t\t\t\t",
+		"o2\tAnimalia\t\t\t\t\tPanthera onca\t\t\t\t",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer from.Close()
+	to, err := OpenArchive(writeArchive(t, dir, "1.1.zip", testOccurrenceArchive(
+		"o4\tAnimalia\t\t\t\t\tPanthera onca\t\t\t\t",
+		"o1\tAnimalia\t\t\t\t\tAra ararauna\t\t\t\t",
+		"o3\tAnimalia\t\t\t\t\tTapirus terrestris (Linnaeus, 1758)\t-3\t-60\t\t",
+		"o4\tAnimalia\t\t\t\t\tPanthera onca\t\t\t\t",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer to.Close()
+
+	changes := []RecordChange{}
+	diff, err := DiffArchives(from, to, func(c RecordChange) error {
+		changes = append(changes, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (ArchiveDiff{Added: 1, Removed: 1, Modified: 1, Unchanged: 1, Duplicates: 2}); diff != want {
+		t.Errorf("got \n%#v, want \n%#v", diff, want)
+	}
+	want := []RecordChange{
+		{"o2", RecordDuplicate, "", "", ""},
+		{"o4", RecordAdded, "", "", ""},
+		{"o3", RecordModified, "decimalLatitude", "", "-3"},
+		{"o3", RecordModified, "decimalLongitude", "", "-60"},
+		{"o3", RecordModified, "scientificName", "Tapirus terrestris", "Tapirus terrestris (Linnaeus, 1758)"},
+		{"o4", RecordDuplicate, "", "", ""},
+		{"o2", RecordRemoved, "", "", ""},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got \n%#v, want \n%#v", changes, want)
+	}
+
+	if _, err := DiffArchives(from, to, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,378 @@
+package iptReport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Styles of xlsx cells, indexes of cellXfs at xlsxStyles.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleInteger
+	xlsxStyleDate
+)
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="3" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+</styleSheet>
+`
+
+// xlsxEpoch is day zero of spreadsheet date serials.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxSheet is a worksheet whose first row is its header. Footer rows
+// follow the rows and are left out of the autofilter.
+type xlsxSheet struct {
+	name   string
+	header []string
+	rows   [][]interface{}
+	footer [][]interface{}
+}
+
+// XLSXWriter writes an Excel workbook with a Summary sheet totaling each IPT
+// and the whole country, a sheet per IPT crawled listing its resources, and
+// an Errors sheet with failed IPTs and resources which failed to bind. Every
+// sheet has an autofilter and a frozen header. The workbook is kept in
+// memory until End.
+type XLSXWriter struct {
+	w       io.Writer
+	summary *xlsxSheet
+	errors  *xlsxSheet
+	sheets  []*xlsxSheet
+	names   map[string]bool
+	current *xlsxSheet
+}
+
+// NewXLSXWriter returns a writer of an xlsx workbook.
+func NewXLSXWriter(w io.Writer) *XLSXWriter {
+	return &XLSXWriter{w: w}
+}
+
+// xlsxSummaryTitles are the columns of the Summary sheet, counts starting at
+// the fifth.
+var xlsxSummaryTitles = []string{"IPT", "URL", "Version", "Status", "Resources", "Occurrences", "Events",
+	"Measurements", "Latest Publication"}
+
+// xlsxResourceTitles are the columns of IPT sheets.
+var xlsxResourceTitles = []string{"Resource", "Link", "Organization", "Type", "Subtype", "Occurrences", "Events",
+	"Measurements", "Records", "Last Modified", "Last Publication", "Next Publication", "Visibility", "Author",
+	"Dataset Key"}
+
+// Begin starts the Summary and Errors sheets.
+func (x *XLSXWriter) Begin() error {
+	x.summary = &xlsxSheet{name: "Summary", header: xlsxSummaryTitles}
+	x.errors = &xlsxSheet{name: "Errors", header: []string{"IPT", "Resource", "Error"}}
+	x.sheets = nil
+	x.names = map[string]bool{"summary": true, "errors": true}
+	return nil
+}
+
+// sheetName returns a valid sheet name for name, unique in the workbook:
+// without the characters sheet names forbid, at most 31 characters long.
+func (x *XLSXWriter) sheetName(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	clean = strings.Trim(clean, "' ")
+	if clean == "" {
+		clean = "IPT"
+	}
+
+	unique := clean
+	for i := 2; ; i++ {
+		if r := []rune(unique); len(r) > 31 {
+			unique = string(r[:31])
+		}
+		if !x.names[strings.ToLower(unique)] {
+			break
+		}
+		suffix := fmt.Sprintf(" (%d)", i)
+		r := []rune(clean)
+		if len(r)+len(suffix) > 31 {
+			r = r[:31-len(suffix)]
+		}
+		unique = string(r) + suffix
+	}
+	x.names[strings.ToLower(unique)] = true
+	return unique
+}
+
+// WriteIPT adds ipt to the summary and its errors to the Errors sheet, and
+// starts its sheet unless it failed.
+func (x *XLSXWriter) WriteIPT(ipt IPT) error {
+	status := "OK"
+	if ipt.Err != nil {
+		status = "Failed"
+		x.errors.rows = append(x.errors.rows, []interface{}{ipt.Name, "", ipt.Err.Error()})
+	}
+	for _, err := range ipt.BindErrs {
+		row := []interface{}{ipt.Name, "", err.Error()}
+		if b, ok := err.(*BindError); ok {
+			row[1], row[2] = b.Resource, b.Err.Error()
+		}
+		x.errors.rows = append(x.errors.rows, row)
+	}
+	x.summary.rows = append(x.summary.rows, []interface{}{ipt.Name, ipt.URL, ipt.Version, status,
+		0, 0, 0, 0, time.Time{}})
+
+	x.current = nil
+	if ipt.Err == nil {
+		x.current = &xlsxSheet{name: x.sheetName(ipt.Name), header: xlsxResourceTitles}
+		x.sheets = append(x.sheets, x.current)
+	}
+	return nil
+}
+
+// WriteResource adds r to the sheet of its IPT and to its summary.
+func (x *XLSXWriter) WriteResource(ipt IPT, r Resource) error {
+	if x.current == nil {
+		return fmt.Errorf("Resource %s written before its IPT %s", r.Name, ipt.Name)
+	}
+	x.current.rows = append(x.current.rows, []interface{}{r.Name, r.Link, r.Organization, r.Type, r.Subtype,
+		r.Occurrences, r.Events, r.Measurements, r.Records, r.LastModified, r.LastPublication,
+		r.NextPublication, r.Visibility, r.Author, r.DatasetKey})
+
+	row := x.summary.rows[len(x.summary.rows)-1]
+	row[4] = row[4].(int) + 1
+	row[5] = row[5].(int) + r.Occurrences
+	row[6] = row[6].(int) + r.Events
+	row[7] = row[7].(int) + r.Measurements
+	if r.LastPublication.After(row[8].(time.Time)) {
+		row[8] = r.LastPublication
+	}
+	return nil
+}
+
+// End totals the summary and writes the workbook.
+func (x *XLSXWriter) End() error {
+	total := []interface{}{"Total", "", "", fmt.Sprintf("%d IPTs", len(x.summary.rows)), 0, 0, 0, 0, time.Time{}}
+	for _, row := range x.summary.rows {
+		for i := 4; i < 8; i++ {
+			total[i] = total[i].(int) + row[i].(int)
+		}
+		if row[8].(time.Time).After(total[8].(time.Time)) {
+			total[8] = row[8]
+		}
+	}
+	x.summary.footer = [][]interface{}{total}
+
+	sheets := append([]*xlsxSheet{x.summary}, x.sheets...)
+	sheets = append(sheets, x.errors)
+
+	z := zip.NewWriter(x.w)
+	add := func(name, content string) error {
+		f, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+
+	if err := add("[Content_Types].xml", xlsxContentTypes(len(sheets))); err != nil {
+		return err
+	}
+	if err := add("_rels/.rels", xlsxRootRels); err != nil {
+		return err
+	}
+	if err := add("xl/workbook.xml", xlsxWorkbook(sheets)); err != nil {
+		return err
+	}
+	if err := add("xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))); err != nil {
+		return err
+	}
+	if err := add("xl/styles.xml", xlsxStyles); err != nil {
+		return err
+	}
+	for i, s := range sheets {
+		if err := add(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), s.xml()); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`
+
+func xlsxContentTypes(sheets int) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(buf, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", i)
+	}
+	buf.WriteString("</Types>\n")
+	return buf.String()
+}
+
+func xlsxWorkbookRels(sheets int) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(buf, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n", i, i)
+	}
+	fmt.Fprintf(buf, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`+"\n", sheets+1)
+	buf.WriteString("</Relationships>\n")
+	return buf.String()
+}
+
+func xlsxWorkbook(sheets []*xlsxSheet) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+`)
+	for i, s := range sheets {
+		fmt.Fprintf(buf, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`+"\n", xmlEscape(s.name), i+1, i+1)
+	}
+	buf.WriteString("</sheets>\n<definedNames>\n")
+	for i, s := range sheets {
+		quoted := "'" + strings.Replace(s.name, "'", "''", -1) + "'"
+		fmt.Fprintf(buf, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">%s!%s</definedName>`+"\n",
+			i, xmlEscape(quoted), xlsxAbsolute(s.filterRef()))
+	}
+	buf.WriteString("</definedNames>\n</workbook>\n")
+	return buf.String()
+}
+
+// xlsxColumn returns the letters of the column at index i, from 0.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxAbsolute turns a range like A1:C4 into $A$1:$C$4.
+func xlsxAbsolute(ref string) string {
+	parts := strings.Split(ref, ":")
+	for i, p := range parts {
+		j := strings.IndexAny(p, "0123456789")
+		parts[i] = "$" + p[:j] + "$" + p[j:]
+	}
+	return strings.Join(parts, ":")
+}
+
+// filterRef returns the range of the header and rows of the sheet.
+func (s *xlsxSheet) filterRef() string {
+	return fmt.Sprintf("A1:%s%d", xlsxColumn(len(s.header)-1), len(s.rows)+1)
+}
+
+func xmlEscape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+// xlsxCell returns the cell at ref holding v: strings inline, integers and
+// times as numbers, the latter formatted as dates. Empty strings and zero
+// times are left out.
+func xlsxCell(ref string, v interface{}, header bool) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return ""
+		}
+		style := ""
+		if header {
+			style = fmt.Sprintf(` s="%d"`, xlsxStyleHeader)
+		}
+		return fmt.Sprintf(`<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(v))
+	case int:
+		return fmt.Sprintf(`<c r="%s" s="%d"><v>%d</v></c>`, ref, xlsxStyleInteger, v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		serial := v.Sub(xlsxEpoch).Hours() / 24
+		return fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, strconv.FormatFloat(serial, 'f', -1, 64))
+	}
+	return ""
+}
+
+// xml returns the worksheet, its header frozen, its columns sized after
+// their contents.
+func (s *xlsxSheet) xml() string {
+	rows := append([][]interface{}{}, s.rows...)
+	rows = append(rows, s.footer...)
+
+	widths := make([]int, len(s.header))
+	for i, h := range s.header {
+		widths[i] = len(h) + 4
+	}
+	for _, row := range rows {
+		for i, v := range row {
+			n := 12
+			if str, ok := v.(string); ok {
+				n = len([]rune(str)) + 2
+			}
+			if n > 60 {
+				n = 60
+			}
+			if n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/><selection pane="bottomLeft" activeCell="A2" sqref="A2"/></sheetView></sheetViews>
+<cols>`)
+	for i, w := range widths {
+		fmt.Fprintf(buf, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, w)
+	}
+	buf.WriteString("</cols>\n<sheetData>\n")
+
+	buf.WriteString(`<row r="1">`)
+	for i, h := range s.header {
+		buf.WriteString(xlsxCell(xlsxColumn(i)+"1", h, true))
+	}
+	buf.WriteString("</row>\n")
+	for r, row := range rows {
+		n := strconv.Itoa(r + 2)
+		fmt.Fprintf(buf, `<row r="%s">`, n)
+		for i, v := range row {
+			buf.WriteString(xlsxCell(xlsxColumn(i)+n, v, false))
+		}
+		buf.WriteString("</row>\n")
+	}
+
+	fmt.Fprintf(buf, "</sheetData>\n<autoFilter ref=\"%s\"/>\n</worksheet>\n", s.filterRef())
+	return buf.String()
+}
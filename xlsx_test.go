@@ -0,0 +1,138 @@
+package iptReport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xlsxTestSheet is the part of a worksheet checked by tests.
+type xlsxTestSheet struct {
+	Pane struct {
+		YSplit int    `xml:"ySplit,attr"`
+		State  string `xml:"state,attr"`
+	} `xml:"sheetViews>sheetView>pane"`
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  int    `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+	AutoFilter struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"autoFilter"`
+}
+
+func TestXLSXWriter(t *testing.T) {
+	published := time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC)
+	ipts := []IPT{
+		{Name: "a", URL: "http://ipt.example.org", Version: "2.3.4", Resources: []Resource{
+			{Name: "Birds", Organization: "INPA", Occurrences: 10, Events: 2, LastPublication: published},
+			{Name: "Fish", Organization: "INPA", Occurrences: 5},
+		}, BindErrs: []error{&BindError{Resource: "Bats", Err: errors.New("bad date")}}},
+		{Name: "b", Err: errors.New("connection refused")},
+		{Name: "a very long alias: with [forbidden] characters"},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteReport(NewXLSXWriter(buf), ipts); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name], _ = ioutil.ReadAll(r)
+		r.Close()
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+		DefinedNames []string `xml:"definedNames>definedName"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, s := range workbook.Sheets {
+		names = append(names, s.Name)
+	}
+	want := []string{"Summary", "a", "a very long alias_ with _forbid", "Errors"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got sheets %q, want %q", names, want)
+	}
+	if len(workbook.DefinedNames) != 4 || workbook.DefinedNames[0] != "'Summary'!$A$1:$I$4" {
+		t.Errorf("got defined names %q", workbook.DefinedNames)
+	}
+
+	sheet := func(i int) xlsxTestSheet {
+		var s xlsxTestSheet
+		if err := xml.Unmarshal(parts["xl/worksheets/sheet"+string(rune('0'+i))+".xml"], &s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Pane.YSplit != 1 || s.Pane.State != "frozen" {
+			t.Errorf("sheet %d: header not frozen", i)
+		}
+		return s
+	}
+
+	summary := sheet(1)
+	if summary.AutoFilter.Ref != "A1:I4" || len(summary.Rows) != 5 {
+		t.Errorf("summary: got filter %s, %d rows", summary.AutoFilter.Ref, len(summary.Rows))
+	}
+	a := summary.Rows[1].Cells
+	if a[0].Inline != "a" || a[4].Value != "2" || a[5].Value != "15" || a[8].Value != "43222" || a[8].Style != xlsxStyleDate {
+		t.Errorf("summary: got %+v", a)
+	}
+	total := summary.Rows[4].Cells
+	if total[0].Inline != "Total" || total[1].Inline != "3 IPTs" || total[3].Ref != "F5" || total[3].Value != "15" {
+		t.Errorf("summary total: got %+v", total)
+	}
+
+	resources := sheet(2)
+	if resources.AutoFilter.Ref != "A1:O3" || len(resources.Rows) != 3 {
+		t.Errorf("a: got filter %s, %d rows", resources.AutoFilter.Ref, len(resources.Rows))
+	}
+	birds := resources.Rows[1].Cells
+	if birds[0].Type != "inlineStr" || birds[2].Ref != "F2" || birds[2].Value != "10" || birds[2].Type != "" ||
+		birds[6].Ref != "K2" || birds[6].Value != "43222" {
+		t.Errorf("a: got %+v", birds)
+	}
+
+	errs := sheet(4)
+	got := []string{}
+	for _, row := range errs.Rows[1:] {
+		cells := []string{}
+		for _, c := range row.Cells {
+			cells = append(cells, c.Ref+"="+c.Inline)
+		}
+		got = append(got, strings.Join(cells, " "))
+	}
+	want = []string{"A2=a B2=Bats C2=bad date", "A3=b C3=connection refused"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors: got %q, want %q", got, want)
+	}
+}